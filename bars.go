@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// Partial blocks, indexed by the number of eighths of a cell they fill
+var barEighths = []string{"", "▏", "▎", "▍", "▌", "▋", "▊", "▉"}
+
+// Bounds on the number of cells a bar may use
+const (
+	MIN_BAR_WIDTH = 10
+	MAX_BAR_WIDTH = 40
+)
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: AppendBars                                                                           *
+*                                                                                            *
+* Description: Adds a cell to each row of a long listing with a bar showing the size of the  *
+*              entry as a share of the total size of the listed entries, followed by the     *
+*              readable size and the percentage. The bars fill the space the table leaves on *
+*              the terminal line. The . and .. entries get an empty cell and do not count    *
+*              towards the total                                                             *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags        - The command line arguments for the program        *
+*              table : [][]string        - The rows of the listing                           *
+*              filesInfo : []fs.FileInfo - The file of each row, in the same order           *
+*                                                                                            *
+* return: none                                                                               *
+**********************************************************************************************/
+func AppendBars(ArgsFlags *Flags, table [][]string, filesInfo []fs.FileInfo) {
+	var total int64
+	sizeWidth := 0
+	for _, info := range filesInfo {
+		if !IsDotEntry(info.Name()) {
+			total += info.Size()
+			sizeWidth = max(sizeWidth, len(GetReadableSize(info.Size())))
+		}
+	}
+
+	// Leave room for the space before the bar and the size and percentage after it
+	width := GetTerminalWidth() - GetTableWidth(table) - 1 - sizeWidth - len("  100.0%") - 1
+	width = min(max(width, MIN_BAR_WIDTH), MAX_BAR_WIDTH)
+	unicode := IsUTF8Locale()
+
+	for idx, info := range filesInfo {
+		if IsDotEntry(info.Name()) {
+			table[idx] = append(table[idx], "")
+			continue
+		}
+
+		var share float64
+		if total > 0 {
+			share = float64(info.Size()) / float64(total)
+		}
+
+		bar := GetBar(share, width, unicode)
+		if !*ArgsFlags.NoColors {
+			bar = GetBarColor(share) + bar + RESET
+		}
+		table[idx] = append(table[idx], fmt.Sprintf("%s %*s  %5.1f%%", bar, sizeWidth, GetReadableSize(info.Size()), share*100))
+	}
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetBar                                                                               *
+*                                                                                            *
+* Description: Draws a bar filling share of width cells, padded with spaces to the full      *
+*              width. With unicode the end of the bar is drawn in eighths of a cell,         *
+*              otherwise whole cells of # are used                                           *
+*                                                                                            *
+* Parameters:  share : float64 - The filled fraction, from 0 to 1                            *
+*              width : int     - The number of cells of a full bar                           *
+*              unicode : bool  - Whether block elements can be printed                       *
+*                                                                                            *
+* return: string - the bar                                                                   *
+**********************************************************************************************/
+func GetBar(share float64, width int, unicode bool) string {
+	if !unicode {
+		filled := int(share*float64(width) + 0.5)
+		return strings.Repeat("#", filled) + strings.Repeat(" ", width-filled)
+	}
+
+	eighths := int(share*float64(width*8) + 0.5)
+	bar := strings.Repeat("█", eighths/8) + barEighths[eighths%8]
+	return bar + strings.Repeat(" ", width-VisibleWidth(bar))
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetBarColor                                                                          *
+*                                                                                            *
+* Description: Returns the color of a bar, going from green to yellow to red as the share of *
+*              the total grows                                                               *
+*                                                                                            *
+* Parameters: share : float64 - The fraction of the total the entry takes                    *
+*                                                                                            *
+* return: string - the color code                                                            *
+**********************************************************************************************/
+func GetBarColor(share float64) string {
+	switch {
+	case share >= 0.5:
+		return RED
+	case share >= 0.2:
+		return YELLOW
+	}
+
+	return GREEN
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetTableWidth                                                                        *
+*                                                                                            *
+* Description: Returns the width of the widest line PrintTable prints for a table            *
+*                                                                                            *
+* Parameters: table : [][]string - The rows of the table                                     *
+*                                                                                            *
+* return: int - the width in columns                                                         *
+**********************************************************************************************/
+func GetTableWidth(table [][]string) int {
+	colSizes := make([]int, 0)
+	for _, row := range table {
+		for coli, col := range row {
+			if coli == len(colSizes) {
+				colSizes = append(colSizes, 0)
+			}
+			colSizes[coli] = max(colSizes[coli], VisibleWidth(col))
+		}
+	}
+
+	width := len(colSizes) - 1 // One space between columns
+	for _, size := range colSizes {
+		width += size
+	}
+
+	return max(width, 0)
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// What the columns of a long listing are computed from
+type ColumnEntry struct {
+	Info      fs.FileInfo
+	Dir       string // The directory the file is in
+	GitStatus GitStatus
+	InGitRepo bool // Only set when the git column is shown
+}
+
+// Describes a column of the long listing format
+type Column struct {
+	Name       string // Used in --columns
+	Header     string // Title of the column
+	AlignRight bool   // Numbers are aligned on their last digit
+	Value      func(ArgsFlags *Flags, entry *ColumnEntry) string
+}
+
+// Every column --columns accepts, in the order they are listed in the usage
+var longColumns = []Column{
+	{"inode", "Inode", true, func(ArgsFlags *Flags, entry *ColumnEntry) string {
+		return fmt.Sprint(GetStatField(entry.Info, "inode"))
+	}},
+	{"perms", "Permissions", false, func(ArgsFlags *Flags, entry *ColumnEntry) string {
+		return GetFilePerms(&entry.Info)
+	}},
+	{"links", "Links", true, func(ArgsFlags *Flags, entry *ColumnEntry) string {
+		return fmt.Sprint(GetStatField(entry.Info, "nlink"))
+	}},
+	{"owner", "Owner", false, func(ArgsFlags *Flags, entry *ColumnEntry) string {
+		return GetOwnerName(entry.Info)
+	}},
+	{"group", "Group", false, func(ArgsFlags *Flags, entry *ColumnEntry) string {
+		return GetGroupName(entry.Info)
+	}},
+	{"uid", "UID", true, func(ArgsFlags *Flags, entry *ColumnEntry) string {
+		return fmt.Sprint(GetStatField(entry.Info, "uid"))
+	}},
+	{"gid", "GID", true, func(ArgsFlags *Flags, entry *ColumnEntry) string {
+		return fmt.Sprint(GetStatField(entry.Info, "gid"))
+	}},
+	{"size", "Size", true, func(ArgsFlags *Flags, entry *ColumnEntry) string {
+		return FormatSize(ArgsFlags, entry.Info.Size())
+	}},
+	{"alloc", "Allocated", true, func(ArgsFlags *Flags, entry *ColumnEntry) string {
+		return FormatSize(ArgsFlags, GetAllocatedSize(entry.Info))
+	}},
+	{"blocks", "Blocks", true, func(ArgsFlags *Flags, entry *ColumnEntry) string {
+		return fmt.Sprint(GetStatField(entry.Info, "blocks"))
+	}},
+	{"dev", "Device", true, func(ArgsFlags *Flags, entry *ColumnEntry) string {
+		return fmt.Sprint(GetStatField(entry.Info, "dev"))
+	}},
+	{"mtime", "Modified", false, func(ArgsFlags *Flags, entry *ColumnEntry) string {
+		return entry.Info.ModTime().Format(ArgsFlags.TimeFormat)
+	}},
+	{"atime", "Accessed", false, func(ArgsFlags *Flags, entry *ColumnEntry) string {
+		return GetStatTime(entry.Info, 'a').Format(ArgsFlags.TimeFormat)
+	}},
+	{"ctime", "Changed", false, func(ArgsFlags *Flags, entry *ColumnEntry) string {
+		return GetStatTime(entry.Info, 'c').Format(ArgsFlags.TimeFormat)
+	}},
+	{"ext", "Ext", false, func(ArgsFlags *Flags, entry *ColumnEntry) string {
+		return GetExtension(entry.Info.Name())
+	}},
+	{"git", "Git", false, func(ArgsFlags *Flags, entry *ColumnEntry) string {
+		if !entry.InGitRepo {
+			return ""
+		} else if *ArgsFlags.NoColors {
+			return string([]byte{entry.GitStatus.Index, entry.GitStatus.Worktree})
+		}
+		return GetColorGitStatus(entry.GitStatus)
+	}},
+	{"path", "Path", false, func(ArgsFlags *Flags, entry *ColumnEntry) string {
+		return JoinPath(entry.Dir, entry.Info.Name())
+	}},
+	{"name", "Name", false, func(ArgsFlags *Flags, entry *ColumnEntry) string {
+		name := FormatIcon(ArgsFlags, entry.Info, FormatFilename(ArgsFlags, entry.Info.Name()))
+		if entry.InGitRepo && !*ArgsFlags.NoColors {
+			name = GetGitColorFilename(entry.Info, name, entry.GitStatus)
+		} else if !*ArgsFlags.NoColors {
+			name = GetColorFilename(entry.Info, name)
+		}
+		return FormatHyperlink(ArgsFlags, JoinPath(entry.Dir, entry.Info.Name()), name) + GetIndicator(ArgsFlags, entry.Info)
+	}},
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetLongColumns                                                                       *
+*                                                                                            *
+* Description: Returns the columns of the long listing format. --columns lists them by name  *
+*              in any order, otherwise the default columns are used along with those turned  *
+*              on by -i, --dir-size, --extension-column and --git                            *
+*                                                                                            *
+* Parameters: ArgsFlags : *Flags - The command line arguments for the program                *
+*                                                                                            *
+* return: []Column - the columns in the order they are printed                               *
+*         error    - non-nil if --columns names an unknown column                            *
+**********************************************************************************************/
+func GetLongColumns(ArgsFlags *Flags) ([]Column, error) {
+	names := []string{"perms", "links", "owner", "group", "size"}
+	if *ArgsFlags.ShowINodes {
+		names = append([]string{"inode"}, names...)
+	}
+	if *ArgsFlags.DirSize {
+		names = append(names, "alloc")
+	}
+	names = append(names, "mtime")
+	if *ArgsFlags.ShowExtension {
+		names = append(names, "ext")
+	}
+	if *ArgsFlags.ShowGit {
+		names = append(names, "git")
+	}
+	names = append(names, "name")
+	if *ArgsFlags.Format == "csv" || *ArgsFlags.Format == "tsv" {
+		names = append(names, "path")
+	}
+
+	if *ArgsFlags.Columns != "" {
+		names = strings.Split(*ArgsFlags.Columns, ",")
+	}
+
+	columns := make([]Column, 0, len(names))
+	for _, name := range names {
+		column, ok := FindColumn(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q, expected one of %s", name, strings.Join(GetColumnNames(), ","))
+		}
+		columns = append(columns, column)
+	}
+
+	return columns, nil
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: FindColumn                                                                           *
+*                                                                                            *
+* Description: Looks up a column of the long listing format by name                          *
+*                                                                                            *
+* Parameters: name : string - The name used in --columns                                     *
+*                                                                                            *
+* return: Column - the column                                                                *
+*         bool   - false if there is no such column                                          *
+**********************************************************************************************/
+func FindColumn(name string) (Column, bool) {
+	for _, column := range longColumns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+
+	return Column{}, false
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetColumnNames                                                                       *
+*                                                                                            *
+* Description: Returns the names of all the columns --columns accepts                        *
+*                                                                                            *
+* Parameters: none                                                                           *
+*                                                                                            *
+* return: []string - the names                                                               *
+**********************************************************************************************/
+func GetColumnNames() []string {
+	names := make([]string, 0, len(longColumns))
+	for _, column := range longColumns {
+		names = append(names, column.Name)
+	}
+
+	return names
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: HasColumn                                                                            *
+*                                                                                            *
+* Description: Tells whether a column is part of the listing                                 *
+*                                                                                            *
+* Parameters:  columns : []Column - The columns of the listing                               *
+*              name : string      - The column to look for                                   *
+*                                                                                            *
+* return: bool                                                                               *
+**********************************************************************************************/
+func HasColumn(columns []Column, name string) bool {
+	return GetColumnIndex(columns, name) >= 0
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetColumnIndex                                                                       *
+*                                                                                            *
+* Description: Returns the position of a column in the listing                               *
+*                                                                                            *
+* Parameters:  columns : []Column - The columns of the listing                               *
+*              name : string      - The column to look for                                   *
+*                                                                                            *
+* return: int - the index, -1 if the column is not part of the listing                       *
+**********************************************************************************************/
+func GetColumnIndex(columns []Column, name string) int {
+	for idx, column := range columns {
+		if column.Name == name {
+			return idx
+		}
+	}
+
+	return -1
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetColumnEntry                                                                       *
+*                                                                                            *
+* Description: Gathers what the columns need to know about a file. The Git status is only    *
+*              looked up when the git column is shown                                        *
+*                                                                                            *
+* Parameters:  columns : []Column  - The columns of the listing                              *
+*              info : fs.FileInfo  - The file                                                *
+*              callingDir : string - The directory the file is in                            *
+*                                                                                            *
+* return: *ColumnEntry - the entry                                                           *
+**********************************************************************************************/
+func GetColumnEntry(columns []Column, info fs.FileInfo, callingDir string) *ColumnEntry {
+	entry := &ColumnEntry{Info: info, Dir: callingDir}
+	if HasColumn(columns, "git") {
+		if absPath, err := filepath.Abs(JoinPath(callingDir, info.Name())); err == nil {
+			entry.GitStatus, entry.InGitRepo = GetGitStatus(absPath, info.IsDir())
+		}
+	}
+
+	return entry
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: FormatSize                                                                           *
+*                                                                                            *
+* Description: Formats a size in bytes, or in human readable units with -h                   *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags - The command line arguments for the program               *
+*              size : int64       - The size in bytes                                        *
+*                                                                                            *
+* return: string - the formatted size                                                        *
+**********************************************************************************************/
+func FormatSize(ArgsFlags *Flags, size int64) string {
+	if *ArgsFlags.HumanReadable {
+		return GetReadableSize(size)
+	}
+
+	return fmt.Sprint(size)
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetHeaderRow                                                                         *
+*                                                                                            *
+* Description: Returns the titles of the columns of the long listing format, bold and        *
+*              underlined unless -G is given. With --bars a title is added for the bar column*
+*                                                                                            *
+* Parameters: ArgsFlags : *Flags - The command line arguments for the program                *
+*                                                                                            *
+* return: []string - one title per column                                                    *
+**********************************************************************************************/
+func GetHeaderRow(ArgsFlags *Flags) []string {
+	row := make([]string, 0, len(ArgsFlags.LongColumns))
+	for _, column := range ArgsFlags.LongColumns {
+		if *ArgsFlags.NoColors {
+			row = append(row, column.Header)
+		} else {
+			row = append(row, BOLD+UNDERLINE+column.Header+RESET)
+		}
+	}
+
+	// The bars of --bars are added after the columns
+	if *ArgsFlags.Bars {
+		if *ArgsFlags.NoColors {
+			row = append(row, "Share")
+		} else {
+			row = append(row, BOLD+UNDERLINE+"Share"+RESET)
+		}
+	}
+
+	return row
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// A writer that counts the bytes written through it, so --dired can tell where each name starts
+type CountingWriter struct {
+	Writer io.Writer
+	Count  int64
+}
+
+func (writer *CountingWriter) Write(data []byte) (int, error) {
+	written, err := writer.Writer.Write(data)
+	writer.Count += int64(written)
+	return written, err
+}
+
+// Standard output of the long listing format, counted for the offsets of --dired
+var output = &CountingWriter{Writer: os.Stdout}
+
+// Start and end offsets of every name and -R directory header printed with --dired
+var diredNames []int64
+var diredSubdirs []int64
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: RecordDiredName                                                                      *
+*                                                                                            *
+* Description: Remembers where a name was printed for the //DIRED// line, as the offset of   *
+*              its first byte and the offset just past its last byte                         *
+*                                                                                            *
+* Parameters:  offsets : *[]int64 - diredNames or diredSubdirs                               *
+*              start : int64      - The offset of the first byte of the name                 *
+*              name : string      - The name as it was printed                               *
+*                                                                                            *
+* return: none                                                                               *
+**********************************************************************************************/
+func RecordDiredName(offsets *[]int64, start int64, name string) {
+	*offsets = append(*offsets, start, start+int64(len(name)))
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: PrintDiredFooter                                                                     *
+*                                                                                            *
+* Description: Ends the output of --dired with the lines Emacs reads to find the names:      *
+*              //DIRED// with the offsets of every file name, //SUBDIRED// with those of the *
+*              -R directory headers when there are any, and //DIRED-OPTIONS// with the       *
+*              quoting style the names were printed in                                       *
+*                                                                                            *
+* Parameters: ArgsFlags : *Flags - The command line arguments for the program                *
+*                                                                                            *
+* return: none                                                                               *
+**********************************************************************************************/
+func PrintDiredFooter(ArgsFlags *Flags) {
+	formatOffsets := func(offsets []int64) string {
+		var strs strings.Builder
+		for _, offset := range offsets {
+			fmt.Fprintf(&strs, " %d", offset)
+		}
+		return strs.String()
+	}
+
+	fmt.Printf("//DIRED//%s\n", formatOffsets(diredNames))
+	if len(diredSubdirs) > 0 {
+		fmt.Printf("//SUBDIRED//%s\n", formatOffsets(diredSubdirs))
+	}
+	fmt.Printf("//DIRED-OPTIONS// --quoting-style=%s\n", ArgsFlags.Quoting)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// A directory listed with the total size of everything below it, used by --dir-size
+type DirSizeInfo struct {
+	fs.FileInfo
+	apparent  int64 // Sum of the sizes of the directory and its contents
+	allocated int64 // Disk space used by the directory and its contents
+}
+
+func (info DirSizeInfo) Size() int64 {
+	return info.apparent
+}
+
+// The result of walking one directory, errors are printed once all walks are done
+type dirSizeResult struct {
+	apparent  int64
+	allocated int64
+	linked    map[DirID]dirSizeResult // Files with several hard links, not part of the sizes above
+	errs      []error
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: AddDirSizes                                                                          *
+*                                                                                            *
+* Description: Replaces every directory in the slice with a DirSizeInfo holding the recursive*
+*              size of its contents, so the size column, -S and the size filters see the     *
+*              total. The directories are walked in parallel, one per CPU at a time. The .   *
+*              and .. entries keep their own size. A file with several hard links is counted *
+*              once: not at all in directories when one of its names is in the slice, else  *
+*              in the first directory holding it, so the sizes add up to the space they use  *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags        - The command line arguments for the program        *
+*              filesInfo : []fs.FileInfo - The files being listed                            *
+*              callingDir : string       - The directory the files are in                    *
+*                                                                                            *
+* return: []fs.FileInfo - the slice with the directories replaced                            *
+**********************************************************************************************/
+func AddDirSizes(ArgsFlags *Flags, filesInfo []fs.FileInfo, callingDir string) []fs.FileInfo {
+	results := make([]dirSizeResult, len(filesInfo))
+	limit := make(chan struct{}, runtime.NumCPU())
+	var wait sync.WaitGroup
+
+	for idx, info := range filesInfo {
+		if !info.IsDir() || IsDotEntry(info.Name()) {
+			continue
+		}
+
+		wait.Add(1)
+		go func(idx int, dir string) {
+			defer wait.Done()
+			limit <- struct{}{}
+			results[idx] = GetDirSize(ArgsFlags, dir)
+			<-limit
+		}(idx, JoinPath(callingDir, info.Name()))
+	}
+	wait.Wait()
+
+	// Files listed next to the directories already show their own size
+	seen := map[DirID]bool{}
+	for _, info := range filesInfo {
+		if !info.IsDir() && GetStatField(info, "nlink") > 1 {
+			seen[DirID{Dev: GetStatField(info, "dev"), Ino: GetStatField(info, "inode")}] = true
+		}
+	}
+
+	withSizes := make([]fs.FileInfo, len(filesInfo))
+	for idx, info := range filesInfo {
+		withSizes[idx] = info
+		if !info.IsDir() || IsDotEntry(info.Name()) {
+			continue
+		}
+
+		for _, err := range results[idx].errs {
+			fmt.Fprintf(os.Stderr, "vls: %s\n", err)
+			exitStatus = max(exitStatus, 1)
+		}
+
+		result := results[idx]
+		for id, linked := range result.linked {
+			if !seen[id] {
+				seen[id] = true
+				result.apparent += linked.apparent
+				result.allocated += linked.allocated
+			}
+		}
+		withSizes[idx] = DirSizeInfo{info, result.apparent, result.allocated}
+	}
+
+	return withSizes
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetDirSize                                                                           *
+*                                                                                            *
+* Description: Walks a directory and adds up the apparent size and the allocated size of the *
+*              directory and everything in it, the way du does. Files with several hard links*
+*              are kept apart so AddDirSizes can count them once across directories, and with*
+*              -x directories on other file systems are not entered. Files removed during the*
+*              walk are skipped                                                              *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags - The command line arguments for the program               *
+*              dir : string       - The directory to measure                                 *
+*                                                                                            *
+* return: dirSizeResult - the sizes and the errors met on the way                            *
+**********************************************************************************************/
+func GetDirSize(ArgsFlags *Flags, dir string) dirSizeResult {
+	result := dirSizeResult{linked: map[DirID]dirSizeResult{}}
+
+	// WalkDir does not follow a symbolic link given as the root
+	if *ArgsFlags.Dereference {
+		if target, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = target
+		}
+	}
+
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				result.errs = append(result.errs, err)
+			}
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil // Removed since the directory was read
+		}
+
+		if *ArgsFlags.OneFileSystem && info.IsDir() && GetStatField(info, "dev") != ArgsFlags.StartDevice {
+			return fs.SkipDir
+		}
+
+		// A file with several names is only counted once, by the caller
+		if !info.IsDir() && GetStatField(info, "nlink") > 1 {
+			id := DirID{Dev: GetStatField(info, "dev"), Ino: GetStatField(info, "inode")}
+			result.linked[id] = dirSizeResult{apparent: info.Size(), allocated: GetAllocatedSize(info)}
+			return nil
+		}
+
+		result.apparent += info.Size()
+		result.allocated += GetAllocatedSize(info)
+		return nil
+	})
+
+	return result
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetAllocatedSize                                                                     *
+*                                                                                            *
+* Description: Returns the disk space used by a file, which differs from its size for sparse *
+*              files and files smaller than a block. Directories measured by --dir-size      *
+*              return the space used by their contents                                       *
+*                                                                                            *
+* Parameters: info : fs.FileInfo - The file                                                  *
+*                                                                                            *
+* return: int64 - the allocated size in bytes                                                *
+**********************************************************************************************/
+func GetAllocatedSize(info fs.FileInfo) int64 {
+	if sized, ok := info.(DirSizeInfo); ok {
+		return sized.allocated
+	}
+
+	// st_blocks is counted in 512 byte units whatever the block size of the file system
+	return int64(GetStatField(info, "blocks")) * 512
+}
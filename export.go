@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// Writes the rows of --format=csv and tsv, created with the header row by the first listing
+var machineWriter *csv.Writer
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: PrintMachineListing                                                                  *
+*                                                                                            *
+* Description: Prints the entries of a directory as CSV or TSV rows with the columns of the  *
+*              long listing format. Fields are quoted as RFC 4180 describes. The whole       *
+*              listing is one table: the header row is printed once and -R adds the rows of  *
+*              the subdirectories to it                                                      *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags        - The command line arguments for the program        *
+*              filesInfo : []fs.FileInfo - The slice of files to print                       *
+*              callingDir: string        - The directory the files are in                    *
+*              depth : int               - The depth of callingDir, 0 for the listed         *
+*                                          directory                                         *
+*                                                                                            *
+* return: none                                                                               *
+**********************************************************************************************/
+func PrintMachineListing(ArgsFlags *Flags, filesInfo []fs.FileInfo, callingDir string, depth int) {
+	filesInfo, dirs, listed := StartListing(ArgsFlags, filesInfo, callingDir, depth, PrintMachineListing)
+	if !listed {
+		return
+	}
+
+	if machineWriter == nil {
+		// RFC 4180 ends CSV records with CRLF, TSV files keep plain newlines
+		machineWriter = csv.NewWriter(os.Stdout)
+		if *ArgsFlags.Format == "tsv" {
+			machineWriter.Comma = '\t'
+		} else {
+			machineWriter.UseCRLF = true
+		}
+
+		header := make([]string, 0, len(ArgsFlags.LongColumns))
+		for _, column := range ArgsFlags.LongColumns {
+			header = append(header, column.Header)
+		}
+		machineWriter.Write(header)
+	}
+
+	for _, group := range GroupFiles(ArgsFlags, filesInfo) {
+		for _, info := range group.Files {
+			entry := GetColumnEntry(ArgsFlags.LongColumns, info, callingDir)
+
+			row := make([]string, 0, len(ArgsFlags.LongColumns))
+			for _, column := range ArgsFlags.LongColumns {
+				row = append(row, column.Value(ArgsFlags, entry))
+			}
+			machineWriter.Write(row)
+		}
+	}
+
+	ListSubDirs(ArgsFlags, dirs, callingDir, depth, PrintMachineListing)
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: FlushMachineListing                                                                  *
+*                                                                                            *
+* Description: Writes out the rows of a CSV or TSV listing still held in the buffer          *
+*                                                                                            *
+* Parameters: none                                                                           *
+*                                                                                            *
+* return: none                                                                               *
+**********************************************************************************************/
+func FlushMachineListing() {
+	if machineWriter == nil {
+		return
+	}
+
+	machineWriter.Flush()
+	if err := machineWriter.Error(); err != nil {
+		fmt.Fprintf(os.Stderr, "vls: %s\n", err)
+		exitStatus = max(exitStatus, 1)
+	}
+}
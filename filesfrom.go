@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// The callingDir of the entries read with --files-from, their names are the paths as given
+const FILES_FROM_DIR = ""
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: ReadPathList                                                                         *
+*                                                                                            *
+* Description: Reads the paths given with --files-from, one per line, or with --files0-from, *
+*              each ended by a NUL byte. The file - stands for stdin. Empty entries are      *
+*              skipped                                                                       *
+*                                                                                            *
+* Parameters:  file : string    - The file holding the paths                                 *
+*              separator : byte - The byte ending each path                                  *
+*                                                                                            *
+* return: []string - the paths in the order they were given                                  *
+*         error    - non-nil if the file could not be read                                   *
+**********************************************************************************************/
+func ReadPathList(file string, separator byte) ([]string, error) {
+	var content []byte
+	var err error
+	if file == "-" {
+		content, err = io.ReadAll(bufio.NewReader(os.Stdin))
+	} else {
+		content, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0)
+	for _, path := range bytes.Split(content, []byte{separator}) {
+		if separator == '\n' {
+			path = bytes.TrimSuffix(path, []byte{'\r'})
+		}
+		if len(path) > 0 {
+			paths = append(paths, string(path))
+		}
+	}
+
+	return paths, nil
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetGivenFilesInfo                                                                    *
+*                                                                                            *
+* Description: Stats the paths given with --files-from or --files0-from so they can be listed*
+*              as the entries of a directory, each named by its path. Paths that do not exist*
+*              are reported and left out. With -L symbolic links are replaced by the file    *
+*              they point to                                                                 *
+*                                                                                            *
+* Parameters: ArgsFlags : *Flags - The command line arguments for the program                *
+*                                                                                            *
+* return: []fs.FileInfo - the entries                                                        *
+*         error         - non-nil if the list of paths could not be read                     *
+**********************************************************************************************/
+func GetGivenFilesInfo(ArgsFlags *Flags) ([]fs.FileInfo, error) {
+	var paths []string
+	var err error
+	if *ArgsFlags.Files0From != "" {
+		paths, err = ReadPathList(*ArgsFlags.Files0From, 0)
+	} else {
+		paths, err = ReadPathList(*ArgsFlags.FilesFrom, '\n')
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	filesInfo := make([]fs.FileInfo, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Lstat(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "vls: cannot access %s\n", strings.TrimPrefix(err.Error(), "lstat "))
+			exitStatus = 2
+			continue
+		}
+
+		if *ArgsFlags.Dereference && info.Mode()&fs.ModeSymlink != 0 {
+			if target, err := os.Stat(path); err == nil {
+				info = target
+			}
+		}
+		filesInfo = append(filesInfo, RenamedFileInfo{info, path})
+	}
+
+	return filesInfo, nil
+}
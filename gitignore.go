@@ -0,0 +1,590 @@
+package main
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// A single pattern read from a .gitignore, .git/info/exclude or global excludes file
+type GitIgnorePattern struct {
+	Regexp   *regexp.Regexp
+	Negate   bool   // The pattern started with ! and re-includes what it matches
+	DirOnly  bool   // The pattern ended with / and only matches directories
+	Anchored bool   // The pattern contains a / and is matched against the whole path below Base
+	Base     string // The directory the pattern is relative to, as a path relative to the work tree root
+}
+
+// Decides which paths of one Git work tree are ignored
+type GitIgnore struct {
+	Root     string                        // Absolute path of the work tree
+	excludes []GitIgnorePattern            // Patterns from the global excludes file and .git/info/exclude
+	perDir   map[string][]GitIgnorePattern // Patterns of each directory's .gitignore, loaded on first use
+	dirCache map[string]bool               // Whether a directory relative to Root is ignored
+}
+
+// Work trees already loaded, keyed on their root
+var gitIgnores = make(map[string]*GitIgnore)
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: FilterGitIgnored                                                                     *
+*                                                                                            *
+* Description: Removes the files Git would ignore from the passed in slice and returns a new *
+*              slice without those files in it. Directories outside of a Git work tree are   *
+*              returned unchanged                                                            *
+*                                                                                            *
+* Parameters:  filesInfo : []fs.FileInfo - The files to filter                               *
+*              callingDir : string       - The directory the files are in                    *
+*                                                                                            *
+* return: []fs.FileInfo - The filtered slice                                                 *
+**********************************************************************************************/
+func FilterGitIgnored(filesInfo []fs.FileInfo, callingDir string) []fs.FileInfo {
+	absDir, err := filepath.Abs(callingDir)
+	if err != nil {
+		return filesInfo
+	}
+
+	gitIgnore := GetGitIgnore(absDir)
+	if gitIgnore == nil {
+		return filesInfo
+	}
+
+	kept := make([]fs.FileInfo, 0, len(filesInfo))
+	for _, file := range filesInfo {
+		if !IsDotEntry(file.Name()) && gitIgnore.IsIgnored(filepath.Join(absDir, file.Name()), file.IsDir()) {
+			continue
+		}
+		kept = append(kept, file)
+	}
+
+	return kept
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: FindGitRoot                                                                          *
+*                                                                                            *
+* Description: Walks up from a directory looking for the root of the Git work tree it is in  *
+*                                                                                            *
+* Parameters: absDir : string - Absolute path of the directory to start from                 *
+*                                                                                            *
+* return: string - the work tree root                                                        *
+*         bool   - false if the directory is not inside a work tree                          *
+**********************************************************************************************/
+func FindGitRoot(absDir string) (string, bool) {
+	for dir := absDir; ; dir = filepath.Dir(dir) {
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			return dir, true
+		}
+
+		if dir == filepath.Dir(dir) {
+			return "", false
+		}
+	}
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetGitDir                                                                            *
+*                                                                                            *
+* Description: Returns the Git directory of a work tree. .git is usually the directory       *
+*              itself, but for linked worktrees and submodules it is a file pointing to it   *
+*                                                                                            *
+* Parameters: root : string - The work tree root                                             *
+*                                                                                            *
+* return: string - the Git directory                                                         *
+**********************************************************************************************/
+func GetGitDir(root string) string {
+	gitDir := filepath.Join(root, ".git")
+	info, err := os.Stat(gitDir)
+	if err != nil || info.IsDir() {
+		return gitDir
+	}
+
+	content, err := os.ReadFile(gitDir)
+	if err != nil {
+		return gitDir
+	}
+
+	target := strings.TrimSpace(strings.TrimPrefix(string(content), "gitdir:"))
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(root, target)
+	}
+	return target
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetGitCommonDir                                                                      *
+*                                                                                            *
+* Description: Returns the directory holding the objects, refs, config and info/exclude of a *
+*              repository. Linked worktrees share these with the main work tree              *
+*                                                                                            *
+* Parameters: gitDir : string - The Git directory of the work tree                           *
+*                                                                                            *
+* return: string - the common directory                                                      *
+**********************************************************************************************/
+func GetGitCommonDir(gitDir string) string {
+	content, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+
+	commonDir := strings.TrimSpace(string(content))
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(gitDir, commonDir)
+	}
+	return commonDir
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetGitIgnore                                                                         *
+*                                                                                            *
+* Description: Returns the ignore rules of the work tree a directory is in, loading the      *
+*              global excludes file and .git/info/exclude the first time the tree is seen    *
+*                                                                                            *
+* Parameters: absDir : string - Absolute path of a directory                                 *
+*                                                                                            *
+* return: *GitIgnore - nil if the directory is not inside a work tree                        *
+**********************************************************************************************/
+func GetGitIgnore(absDir string) *GitIgnore {
+	root, ok := FindGitRoot(absDir)
+	if !ok {
+		return nil
+	}
+
+	if gitIgnore, ok := gitIgnores[root]; ok {
+		return gitIgnore
+	}
+
+	commonDir := GetGitCommonDir(GetGitDir(root))
+	gitIgnore := &GitIgnore{
+		Root:     root,
+		perDir:   make(map[string][]GitIgnorePattern),
+		dirCache: make(map[string]bool),
+	}
+	gitIgnore.excludes = append(gitIgnore.excludes, ReadGitIgnoreFile(GetGlobalExcludesFile(commonDir), "")...)
+	gitIgnore.excludes = append(gitIgnore.excludes, ReadGitIgnoreFile(filepath.Join(commonDir, "info", "exclude"), "")...)
+
+	gitIgnores[root] = gitIgnore
+	return gitIgnore
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetGlobalExcludesFile                                                                *
+*                                                                                            *
+* Description: Finds the global excludes file from core.excludesFile in the user and         *
+*              repository config, falling back to Git's default of                           *
+*              $XDG_CONFIG_HOME/git/ignore                                                   *
+*                                                                                            *
+* Parameters: commonDir : string - The common directory of the repository                    *
+*                                                                                            *
+* return: string - the path of the excludes file, it might not exist                         *
+**********************************************************************************************/
+func GetGlobalExcludesFile(commonDir string) string {
+	home, _ := os.UserHomeDir()
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(home, ".config")
+	}
+
+	excludesFile := filepath.Join(configHome, "git", "ignore")
+
+	// Later config files take priority, the same as in Git
+	configFiles := []string{
+		filepath.Join(configHome, "git", "config"),
+		filepath.Join(home, ".gitconfig"),
+		filepath.Join(commonDir, "config"),
+	}
+	for _, configFile := range configFiles {
+		if value, ok := ReadGitConfigValue(configFile, "core", "excludesfile"); ok {
+			if strings.HasPrefix(value, "~/") {
+				value = filepath.Join(home, value[2:])
+			}
+			excludesFile = value
+		}
+	}
+
+	return excludesFile
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: ReadGitConfigValue                                                                   *
+*                                                                                            *
+* Description: Reads a single value from a Git config file. Only plain [section] headers are *
+*              understood, which is all core.excludesFile needs                              *
+*                                                                                            *
+* Parameters:  configFile : string - The config file to read                                 *
+*              section : string    - The section name in lower case                          *
+*              key : string        - The key name in lower case                              *
+*                                                                                            *
+* return: string - the last value given for the key                                          *
+*         bool   - false if the file or key does not exist                                   *
+**********************************************************************************************/
+func ReadGitConfigValue(configFile string, section string, key string) (string, bool) {
+	file, err := os.Open(configFile)
+	if err != nil {
+		return "", false
+	}
+	defer file.Close()
+
+	var value string
+	var found bool
+	var curSection string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' {
+			curSection = strings.ToLower(strings.Trim(line, "[] \t"))
+			continue
+		}
+
+		name, curValue, _ := strings.Cut(line, "=")
+		if curSection != section || strings.ToLower(strings.TrimSpace(name)) != key {
+			continue
+		}
+
+		curValue = strings.TrimSpace(curValue)
+		if strings.HasPrefix(curValue, "\"") {
+			curValue = strings.Trim(curValue, "\"")
+		} else if commentIdx := strings.IndexAny(curValue, "#;"); commentIdx >= 0 {
+			curValue = strings.TrimSpace(curValue[:commentIdx])
+		}
+		value, found = curValue, true
+	}
+
+	return value, found
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: ReadGitIgnoreFile                                                                    *
+*                                                                                            *
+* Description: Reads the patterns of an ignore file. Missing files have no patterns          *
+*                                                                                            *
+* Parameters:  ignoreFile : string - The file to read                                        *
+*              base : string       - The directory the patterns are relative to, relative to *
+*                                    the work tree root                                      *
+*                                                                                            *
+* return: []GitIgnorePattern - the patterns in the order they appear in the file             *
+**********************************************************************************************/
+func ReadGitIgnoreFile(ignoreFile string, base string) []GitIgnorePattern {
+	file, err := os.Open(ignoreFile)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	patterns := make([]GitIgnorePattern, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		pattern, ok := ParseGitIgnoreLine(scanner.Text(), base)
+		if ok {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	return patterns
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: ParseGitIgnoreLine                                                                   *
+*                                                                                            *
+* Description: Parses one line of an ignore file following gitignore(5): blank lines and     *
+*              comments are skipped, trailing spaces are dropped unless escaped, ! negates, a*
+*              trailing / only matches directories and a / anywhere else anchors the pattern *
+*              to the directory of the ignore file                                           *
+*                                                                                            *
+* Parameters:  line : string - The line to parse                                             *
+*              base : string - The directory the pattern is relative to                      *
+*                                                                                            *
+* return: GitIgnorePattern - the parsed pattern                                              *
+*         bool             - false if the line holds no pattern                              *
+**********************************************************************************************/
+func ParseGitIgnoreLine(line string, base string) (GitIgnorePattern, bool) {
+	pattern := GitIgnorePattern{Base: base}
+
+	line = strings.TrimSuffix(line, "\r")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+
+	if line == "" || line[0] == '#' {
+		return pattern, false
+	}
+
+	if line[0] == '!' {
+		pattern.Negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		pattern.DirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	if strings.Contains(line, "/") {
+		pattern.Anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	if line == "" {
+		return pattern, false
+	}
+
+	compiled, err := regexp.Compile("^" + GitGlobToRegexp(line) + "$")
+	if err != nil {
+		return pattern, false
+	}
+	pattern.Regexp = compiled
+
+	return pattern, true
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GitGlobToRegexp                                                                      *
+*                                                                                            *
+* Description: Converts a gitignore glob into a regular expression. * and ? do not match /, a*
+*              leading **\/ matches in all directories, a trailing /** matches everything    *
+*              inside and /**\/ matches zero or more directories                             *
+*                                                                                            *
+* Parameters: glob : string - The pattern without its !, leading / or trailing /             *
+*                                                                                            *
+* return: string - the regular expression, without anchors                                   *
+**********************************************************************************************/
+func GitGlobToRegexp(glob string) string {
+	var expr strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch glob[i] {
+		case '*':
+			stars := i
+			for i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+			}
+
+			// ** only has its special meaning as a whole path component
+			wholeComponent := (stars == 0 || glob[stars-1] == '/') && (i+1 == len(glob) || glob[i+1] == '/')
+			if i > stars && wholeComponent {
+				if i+1 == len(glob) {
+					expr.WriteString(".*")
+				} else {
+					expr.WriteString("(?:.*/)?")
+					i++
+				}
+			} else {
+				expr.WriteString("[^/]*")
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		case '[':
+			end := i + 1
+			if end < len(glob) && (glob[end] == '!' || glob[end] == '^') {
+				end++
+			}
+			if end < len(glob) && glob[end] == ']' {
+				end++
+			}
+			for end < len(glob) && glob[end] != ']' {
+				end++
+			}
+
+			// An unterminated class is a literal [
+			if end >= len(glob) {
+				expr.WriteString("\\[")
+				continue
+			}
+
+			class := glob[i+1 : end]
+			expr.WriteString("[")
+			if class[0] == '!' || class[0] == '^' {
+				expr.WriteString("^")
+				class = class[1:]
+			}
+			for j := 0; j < len(class); j++ {
+				if class[j] == '\\' && j+1 < len(class) {
+					j++
+				}
+				if class[j] == '\\' || class[j] == '[' && !strings.HasPrefix(class[j:], "[:") || class[j] == ']' {
+					expr.WriteString("\\")
+				}
+				expr.WriteByte(class[j])
+			}
+			expr.WriteString("]")
+			i = end
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+			}
+			expr.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(glob[i])))
+		}
+	}
+
+	return expr.String()
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: IsIgnored                                                                            *
+*                                                                                            *
+* Description: Reports whether Git would ignore a path. A path inside an ignored directory is*
+*              always ignored, as Git does not look into excluded directories. The .git      *
+*              directory itself is always treated as ignored                                 *
+*                                                                                            *
+* Parameters:  absPath : string - Absolute path of the file                                  *
+*              isDir : bool     - Whether the file is a directory                            *
+*                                                                                            *
+* return: bool                                                                               *
+**********************************************************************************************/
+func (gitIgnore *GitIgnore) IsIgnored(absPath string, isDir bool) bool {
+	relPath, err := filepath.Rel(gitIgnore.Root, absPath)
+	if err != nil || relPath == "." || strings.HasPrefix(relPath, "..") {
+		return false
+	}
+	relPath = filepath.ToSlash(relPath)
+
+	if relPath == ".git" || strings.HasPrefix(relPath, ".git/") {
+		return true
+	}
+
+	if parent := path.Dir(relPath); parent != "." && gitIgnore.isDirIgnored(parent) {
+		return true
+	}
+
+	return gitIgnore.matches(relPath, isDir)
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: isDirIgnored                                                                         *
+*                                                                                            *
+* Description: Reports whether a directory or any of its parents is ignored, caching the     *
+*              answer since every file in a listing asks about the same parents              *
+*                                                                                            *
+* Parameters: relDir : string - The directory relative to the work tree root                 *
+*                                                                                            *
+* return: bool                                                                               *
+**********************************************************************************************/
+func (gitIgnore *GitIgnore) isDirIgnored(relDir string) bool {
+	if ignored, ok := gitIgnore.dirCache[relDir]; ok {
+		return ignored
+	}
+
+	ignored := false
+	if parent := path.Dir(relDir); parent != "." {
+		ignored = gitIgnore.isDirIgnored(parent)
+	}
+	if !ignored {
+		ignored = relDir == ".git" || gitIgnore.matches(relDir, true)
+	}
+
+	gitIgnore.dirCache[relDir] = ignored
+	return ignored
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: matches                                                                              *
+*                                                                                            *
+* Description: Checks a path against every pattern that applies to it, lowest priority first:*
+*              the excludes files, then each .gitignore from the root down to the file's     *
+*              directory. The last pattern that matches decides                              *
+*                                                                                            *
+* Parameters:  relPath : string - The path relative to the work tree root                    *
+*              isDir : bool     - Whether the path is a directory                            *
+*                                                                                            *
+* return: bool - true if the path is ignored                                                 *
+**********************************************************************************************/
+func (gitIgnore *GitIgnore) matches(relPath string, isDir bool) bool {
+	ignored := false
+	check := func(patterns []GitIgnorePattern) {
+		for _, pattern := range patterns {
+			if pattern.Match(relPath, isDir) {
+				ignored = !pattern.Negate
+			}
+		}
+	}
+
+	check(gitIgnore.excludes)
+
+	dirs := []string{""}
+	if parent := path.Dir(relPath); parent != "." {
+		components := strings.Split(parent, "/")
+		for idx := range components {
+			dirs = append(dirs, strings.Join(components[:idx+1], "/"))
+		}
+	}
+	for _, dir := range dirs {
+		check(gitIgnore.getDirPatterns(dir))
+	}
+
+	return ignored
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: getDirPatterns                                                                       *
+*                                                                                            *
+* Description: Returns the patterns of a directory's .gitignore, reading it the first time   *
+*                                                                                            *
+* Parameters: relDir : string - The directory relative to the work tree root, "" for the root*
+*                                                                                            *
+* return: []GitIgnorePattern                                                                 *
+**********************************************************************************************/
+func (gitIgnore *GitIgnore) getDirPatterns(relDir string) []GitIgnorePattern {
+	patterns, ok := gitIgnore.perDir[relDir]
+	if !ok {
+		patterns = ReadGitIgnoreFile(filepath.Join(gitIgnore.Root, relDir, ".gitignore"), relDir)
+		gitIgnore.perDir[relDir] = patterns
+	}
+
+	return patterns
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: Match                                                                                *
+*                                                                                            *
+* Description: Reports whether a pattern matches a path. Unanchored patterns only look at the*
+*              last path component, anchored ones at the path below the pattern's Base       *
+*                                                                                            *
+* Parameters:  relPath : string - The path relative to the work tree root                    *
+*              isDir : bool     - Whether the path is a directory                            *
+*                                                                                            *
+* return: bool                                                                               *
+**********************************************************************************************/
+func (pattern GitIgnorePattern) Match(relPath string, isDir bool) bool {
+	if pattern.DirOnly && !isDir {
+		return false
+	}
+
+	if pattern.Base != "" {
+		if !strings.HasPrefix(relPath, pattern.Base+"/") {
+			return false
+		}
+		relPath = relPath[len(pattern.Base)+1:]
+	}
+
+	if !pattern.Anchored {
+		relPath = path.Base(relPath)
+	}
+
+	return pattern.Regexp.MatchString(relPath)
+}
@@ -0,0 +1,1207 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Git file modes as stored in the index and in tree objects
+const (
+	GIT_MODE_TYPE    = 0170000
+	GIT_MODE_DIR     = 0040000
+	GIT_MODE_FILE    = 0100000
+	GIT_MODE_SYMLINK = 0120000
+	GIT_MODE_GITLINK = 0160000
+)
+
+// Object types stored in pack files
+const (
+	GIT_OBJ_COMMIT    = 1
+	GIT_OBJ_TREE      = 2
+	GIT_OBJ_BLOB      = 3
+	GIT_OBJ_TAG       = 4
+	GIT_OBJ_OFS_DELTA = 6
+	GIT_OBJ_REF_DELTA = 7
+)
+
+// The status of a path in the two columns of git status --short: the index compared to HEAD
+// and the work tree compared to the index. '-' means unchanged
+type GitStatus struct {
+	Index    byte
+	Worktree byte
+}
+
+// One entry of the .git/index file
+type GitIndexEntry struct {
+	Path  string
+	Mode  uint32
+	Size  uint32
+	MSec  uint32
+	MNsec uint32
+	Hash  [20]byte
+	Stage uint16
+}
+
+// One file of the tree HEAD points to
+type GitTreeEntry struct {
+	Mode uint32
+	Hash [20]byte
+}
+
+// An opened pack file and its index, used to read packed objects
+type GitPack struct {
+	packFile *os.File
+	names    []byte   // The sorted object names, 20 bytes each
+	offsets  []uint64 // The pack offset of each object, in the same order as names
+	fanout   [256]uint32
+}
+
+// Everything needed to work out the status of the paths of one work tree
+type GitRepo struct {
+	Root       string
+	gitDir     string
+	objectDirs []string
+	packs      []*GitPack
+	index      map[string]GitIndexEntry
+	head       map[string]GitTreeEntry
+	paths      []string        // Sorted union of the index and HEAD paths
+	renamed    map[string]bool // Both paths of every rename, so the old one does not show as deleted
+	ignore     *GitIgnore
+	statuses   map[string]GitStatus
+}
+
+// Work trees already opened, keyed on their root. Repositories that could not be read are
+// stored as nil so the error is only reported once
+var gitRepos = make(map[string]*GitRepo)
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetGitStatus                                                                         *
+*                                                                                            *
+* Description: Returns the Git status of a listed file. Directories get the combined status  *
+*              of everything below them                                                      *
+*                                                                                            *
+* Parameters:  absPath : string - Absolute path of the file                                  *
+*              isDir : bool     - Whether the file is a directory                            *
+*                                                                                            *
+* return: GitStatus - the status of the file                                                 *
+*         bool      - false if the file is not inside a readable Git work tree               *
+**********************************************************************************************/
+func GetGitStatus(absPath string, isDir bool) (GitStatus, bool) {
+	repoDir := absPath
+	if !isDir {
+		repoDir = filepath.Dir(absPath)
+	}
+
+	repo := GetGitRepo(repoDir)
+	if repo == nil {
+		return GitStatus{}, false
+	}
+
+	relPath, err := filepath.Rel(repo.Root, absPath)
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		return GitStatus{}, false
+	}
+	relPath = filepath.ToSlash(relPath)
+
+	if relPath == ".git" || strings.HasPrefix(relPath, ".git/") {
+		return GitStatus{'!', '!'}, true
+	}
+
+	if isDir {
+		return repo.DirStatus(relPath), true
+	}
+	return repo.FileStatus(relPath), true
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetGitRepo                                                                           *
+*                                                                                            *
+* Description: Returns the repository a directory is in, reading its index and HEAD tree the *
+*              first time the work tree is seen. Errors are printed once                     *
+*                                                                                            *
+* Parameters: absDir : string - Absolute path of a directory                                 *
+*                                                                                            *
+* return: *GitRepo - nil if the directory is not in a work tree or it could not be read      *
+**********************************************************************************************/
+func GetGitRepo(absDir string) *GitRepo {
+	root, ok := FindGitRoot(absDir)
+	if !ok {
+		return nil
+	}
+
+	if repo, ok := gitRepos[root]; ok {
+		return repo
+	}
+
+	repo, err := OpenGitRepo(root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading Git repository %s: %s\n", root, err)
+	}
+	gitRepos[root] = repo
+	return repo
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: OpenGitRepo                                                                          *
+*                                                                                            *
+* Description: Reads the index, the pack files and the tree of the HEAD commit of a work     *
+*              tree. A repository without commits has an empty HEAD tree                     *
+*                                                                                            *
+* Parameters: root : string - The work tree root                                             *
+*                                                                                            *
+* return: *GitRepo - the opened repository                                                   *
+*         error    - non-nil if the repository could not be read                             *
+**********************************************************************************************/
+func OpenGitRepo(root string) (*GitRepo, error) {
+	gitDir := GetGitDir(root)
+	commonDir := GetGitCommonDir(gitDir)
+
+	if format, ok := ReadGitConfigValue(filepath.Join(commonDir, "config"), "extensions", "objectformat"); ok && format != "sha1" {
+		return nil, fmt.Errorf("object format %s is not supported", format)
+	}
+
+	repo := &GitRepo{
+		Root:     root,
+		gitDir:   gitDir,
+		index:    make(map[string]GitIndexEntry),
+		head:     make(map[string]GitTreeEntry),
+		renamed:  make(map[string]bool),
+		ignore:   GetGitIgnore(root),
+		statuses: make(map[string]GitStatus),
+	}
+
+	repo.objectDirs = GetGitObjectDirs(filepath.Join(commonDir, "objects"))
+	for _, objectDir := range repo.objectDirs {
+		packs, err := OpenGitPacks(filepath.Join(objectDir, "pack"))
+		if err != nil {
+			return nil, err
+		}
+		repo.packs = append(repo.packs, packs...)
+	}
+
+	entries, err := ReadGitIndex(filepath.Join(gitDir, "index"))
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if existing, ok := repo.index[entry.Path]; !ok || entry.Stage > existing.Stage {
+			repo.index[entry.Path] = entry
+		}
+	}
+
+	headHash, ok, err := repo.ResolveRef("HEAD")
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		_, commit, err := repo.ReadObject(headHash)
+		if err != nil {
+			return nil, err
+		}
+
+		treeHex, found := strings.CutPrefix(string(commit), "tree ")
+		if !found || len(treeHex) < 40 {
+			return nil, errors.New("HEAD commit has no tree")
+		}
+		treeHash, err := ParseGitHash(treeHex[:40])
+		if err != nil {
+			return nil, err
+		}
+
+		if err := repo.ReadTree(treeHash, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	repo.FindRenames()
+
+	seen := make(map[string]bool)
+	for filePath := range repo.index {
+		seen[filePath] = true
+		repo.paths = append(repo.paths, filePath)
+	}
+	for filePath := range repo.head {
+		if !seen[filePath] {
+			repo.paths = append(repo.paths, filePath)
+		}
+	}
+	sort.Strings(repo.paths)
+
+	return repo, nil
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetGitObjectDirs                                                                     *
+*                                                                                            *
+* Description: Returns the object directory of a repository followed by the alternates it    *
+*              borrows objects from                                                          *
+*                                                                                            *
+* Parameters: objectDir : string - The objects directory of the repository                   *
+*                                                                                            *
+* return: []string - the object directories to search                                        *
+**********************************************************************************************/
+func GetGitObjectDirs(objectDir string) []string {
+	objectDirs := []string{objectDir}
+
+	alternates, err := os.ReadFile(filepath.Join(objectDir, "info", "alternates"))
+	if err != nil {
+		return objectDirs
+	}
+
+	for _, line := range strings.Split(string(alternates), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(objectDir, line)
+		}
+		objectDirs = append(objectDirs, line)
+	}
+
+	return objectDirs
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: ReadGitIndex                                                                         *
+*                                                                                            *
+* Description: Parses a .git/index file of version 2, 3 or 4. Extensions after the entries   *
+*              are not needed and skipped. A missing index has no entries                    *
+*                                                                                            *
+* Parameters: indexFile : string - The path of the index file                                *
+*                                                                                            *
+* return: []GitIndexEntry - the entries in the order they are stored                         *
+*         error           - non-nil if the file is not a valid index                         *
+**********************************************************************************************/
+func ReadGitIndex(indexFile string) ([]GitIndexEntry, error) {
+	data, err := os.ReadFile(indexFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 12 || string(data[:4]) != "DIRC" {
+		return nil, errors.New("index has an invalid signature")
+	}
+
+	version := binary.BigEndian.Uint32(data[4:8])
+	if version < 2 || version > 4 {
+		return nil, fmt.Errorf("index version %d is not supported", version)
+	}
+
+	count := binary.BigEndian.Uint32(data[8:12])
+	entries := make([]GitIndexEntry, 0, count)
+	pos := 12
+	var prevPath string
+	for idx := uint32(0); idx < count; idx++ {
+		start := pos
+		if pos+62 > len(data) {
+			return nil, errors.New("index is truncated")
+		}
+
+		var entry GitIndexEntry
+		entry.MSec = binary.BigEndian.Uint32(data[pos+8:])
+		entry.MNsec = binary.BigEndian.Uint32(data[pos+12:])
+		entry.Mode = binary.BigEndian.Uint32(data[pos+24:])
+		entry.Size = binary.BigEndian.Uint32(data[pos+36:])
+		copy(entry.Hash[:], data[pos+40:pos+60])
+		flags := binary.BigEndian.Uint16(data[pos+60:])
+		entry.Stage = (flags >> 12) & 3
+		pos += 62
+
+		// Version 3 and up may have a second flags field
+		if version >= 3 && flags&0x4000 != 0 {
+			pos += 2
+		}
+
+		if version == 4 {
+			// The path is stored as the number of bytes to drop from the previous path and a suffix
+			strip, read := ReadGitOffset(data[pos:])
+			if read == 0 || int(strip) > len(prevPath) {
+				return nil, errors.New("index has an invalid path")
+			}
+			pos += read
+
+			end := bytes.IndexByte(data[pos:], 0)
+			if end < 0 {
+				return nil, errors.New("index is truncated")
+			}
+			entry.Path = prevPath[:len(prevPath)-int(strip)] + string(data[pos:pos+end])
+			pos += end + 1
+		} else {
+			// The path is NUL terminated and the entry is padded to a multiple of 8 bytes
+			end := bytes.IndexByte(data[pos:], 0)
+			if end < 0 {
+				return nil, errors.New("index is truncated")
+			}
+			entry.Path = string(data[pos : pos+end])
+			pos += end + 1
+			for (pos-start)%8 != 0 {
+				pos++
+			}
+		}
+
+		prevPath = entry.Path
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: ReadGitOffset                                                                        *
+*                                                                                            *
+* Description: Decodes the variable length integer Git uses for index v4 path prefixes and   *
+*              OFS_DELTA base offsets                                                        *
+*                                                                                            *
+* Parameters: data : []byte - The bytes starting at the integer                              *
+*                                                                                            *
+* return: uint64 - the value                                                                 *
+*         int    - the number of bytes read, 0 if the data ended early                       *
+**********************************************************************************************/
+func ReadGitOffset(data []byte) (uint64, int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+
+	value := uint64(data[0] & 0x7f)
+	read := 1
+	for data[read-1]&0x80 != 0 {
+		if read >= len(data) {
+			return 0, 0
+		}
+		value = ((value + 1) << 7) | uint64(data[read]&0x7f)
+		read++
+	}
+
+	return value, read
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: ParseGitHash                                                                         *
+*                                                                                            *
+* Description: Converts a 40 character hex object name into its binary form                  *
+*                                                                                            *
+* Parameters: hexHash : string - The object name                                             *
+*                                                                                            *
+* return: [20]byte - the binary object name                                                  *
+*         error    - non-nil if the name is not valid hex                                    *
+**********************************************************************************************/
+func ParseGitHash(hexHash string) ([20]byte, error) {
+	var hash [20]byte
+	decoded, err := hex.DecodeString(hexHash)
+	if err != nil || len(decoded) != len(hash) {
+		return hash, fmt.Errorf("invalid object name %q", hexHash)
+	}
+
+	copy(hash[:], decoded)
+	return hash, nil
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: ResolveRef                                                                           *
+*                                                                                            *
+* Description: Follows a ref such as HEAD or refs/heads/main to the commit it points to,     *
+*              looking at loose refs first and then at packed-refs                           *
+*                                                                                            *
+* Parameters: refName : string - The ref to resolve                                          *
+*                                                                                            *
+* return: [20]byte - the commit                                                              *
+*         bool     - false if the ref does not exist yet, as on an unborn branch             *
+*         error    - non-nil if the ref could not be read                                    *
+**********************************************************************************************/
+func (repo *GitRepo) ResolveRef(refName string) ([20]byte, bool, error) {
+	commonDir := GetGitCommonDir(repo.gitDir)
+
+	for depth := 0; depth < 10; depth++ {
+		// HEAD and other per worktree refs live in the git dir, branches in the common dir
+		content, err := os.ReadFile(filepath.Join(repo.gitDir, refName))
+		if errors.Is(err, fs.ErrNotExist) {
+			content, err = os.ReadFile(filepath.Join(commonDir, refName))
+		}
+
+		if errors.Is(err, fs.ErrNotExist) {
+			hash, ok := ReadPackedRef(filepath.Join(commonDir, "packed-refs"), refName)
+			return hash, ok, nil
+		} else if err != nil {
+			return [20]byte{}, false, err
+		}
+
+		value := strings.TrimSpace(string(content))
+		if target, ok := strings.CutPrefix(value, "ref:"); ok {
+			refName = strings.TrimSpace(target)
+			continue
+		}
+
+		hash, err := ParseGitHash(value)
+		return hash, err == nil, err
+	}
+
+	return [20]byte{}, false, fmt.Errorf("ref %s is nested too deeply", refName)
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: ReadPackedRef                                                                        *
+*                                                                                            *
+* Description: Looks a ref up in a packed-refs file                                          *
+*                                                                                            *
+* Parameters:  packedRefs : string - The path of the packed-refs file                        *
+*              refName : string    - The full name of the ref                                *
+*                                                                                            *
+* return: [20]byte - the object the ref points to                                            *
+*         bool     - false if the ref is not in the file                                     *
+**********************************************************************************************/
+func ReadPackedRef(packedRefs string, refName string) ([20]byte, bool) {
+	content, err := os.ReadFile(packedRefs)
+	if err != nil {
+		return [20]byte{}, false
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		hexHash, name, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok || name != refName {
+			continue
+		}
+
+		hash, err := ParseGitHash(hexHash)
+		return hash, err == nil
+	}
+
+	return [20]byte{}, false
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: ReadTree                                                                             *
+*                                                                                            *
+* Description: Adds every file of a tree object and its subtrees to the HEAD map of the repo *
+*                                                                                            *
+* Parameters:  treeHash : [20]byte - The tree to read                                        *
+*              prefix : string     - The path of the tree relative to the work tree root     *
+*                                                                                            *
+* return: error - non-nil if an object could not be read                                     *
+**********************************************************************************************/
+func (repo *GitRepo) ReadTree(treeHash [20]byte, prefix string) error {
+	_, tree, err := repo.ReadObject(treeHash)
+	if err != nil {
+		return err
+	}
+
+	for len(tree) > 0 {
+		space := bytes.IndexByte(tree, ' ')
+		nul := bytes.IndexByte(tree, 0)
+		if space < 0 || nul < space || nul+21 > len(tree) {
+			return errors.New("tree object is corrupt")
+		}
+
+		mode, err := strconv.ParseUint(string(tree[:space]), 8, 32)
+		if err != nil {
+			return errors.New("tree object is corrupt")
+		}
+		name := prefix + string(tree[space+1:nul])
+
+		var entry GitTreeEntry
+		entry.Mode = uint32(mode)
+		copy(entry.Hash[:], tree[nul+1:nul+21])
+		tree = tree[nul+21:]
+
+		if entry.Mode&GIT_MODE_TYPE == GIT_MODE_DIR {
+			if err := repo.ReadTree(entry.Hash, name+"/"); err != nil {
+				return err
+			}
+		} else {
+			repo.head[name] = entry
+		}
+	}
+
+	return nil
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: FindRenames                                                                          *
+*                                                                                            *
+* Description: Marks staged files as renamed when their content is exactly that of a file    *
+*              that was removed from the index. The removed file is marked too, a rename is  *
+*              one change as in git status. Git also detects renames with edits, which is    *
+*              left out here                                                                 *
+*                                                                                            *
+* Parameters: none                                                                           *
+*                                                                                            *
+* return: none                                                                               *
+**********************************************************************************************/
+func (repo *GitRepo) FindRenames() {
+	removed := make(map[[20]byte]string)
+	for filePath, entry := range repo.head {
+		if _, ok := repo.index[filePath]; !ok {
+			removed[entry.Hash] = filePath
+		}
+	}
+
+	for filePath, entry := range repo.index {
+		if oldPath, ok := removed[entry.Hash]; ok && !repo.renamed[oldPath] {
+			if _, inHead := repo.head[filePath]; !inHead {
+				repo.renamed[filePath] = true
+				repo.renamed[oldPath] = true
+			}
+		}
+	}
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: FileStatus                                                                           *
+*                                                                                            *
+* Description: Works out the status of a single path. Paths missing from the index are       *
+*              either ignored (!!) or untracked (??)                                         *
+*                                                                                            *
+* Parameters: relPath : string - The path relative to the work tree root                     *
+*                                                                                            *
+* return: GitStatus                                                                          *
+**********************************************************************************************/
+func (repo *GitRepo) FileStatus(relPath string) GitStatus {
+	if status, ok := repo.statuses[relPath]; ok {
+		return status
+	}
+
+	status := GitStatus{'-', '-'}
+	entry, inIndex := repo.index[relPath]
+	headEntry, inHead := repo.head[relPath]
+
+	if !inIndex && !inHead {
+		absPath := filepath.Join(repo.Root, relPath)
+		if repo.ignore != nil && repo.ignore.IsIgnored(absPath, false) {
+			status = GitStatus{'!', '!'}
+		} else {
+			status = GitStatus{'?', '?'}
+		}
+	} else if !inIndex && repo.renamed[relPath] {
+		status.Index = 'R'
+	} else if !inIndex {
+		status.Index = 'D'
+	} else if entry.Stage != 0 {
+		status = GitStatus{'U', 'U'}
+	} else {
+		if repo.renamed[relPath] {
+			status.Index = 'R'
+		} else if !inHead {
+			status.Index = 'A'
+		} else if headEntry.Hash != entry.Hash || headEntry.Mode != entry.Mode {
+			status.Index = 'M'
+		}
+		status.Worktree = repo.WorktreeStatus(entry)
+	}
+
+	repo.statuses[relPath] = status
+	return status
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: WorktreeStatus                                                                       *
+*                                                                                            *
+* Description: Compares a file in the work tree with its index entry. Files whose size and   *
+*              modification time still match the index are trusted to be unchanged, others   *
+*              are hashed and compared with the staged object                                *
+*                                                                                            *
+* Parameters: entry : GitIndexEntry - The index entry of the file                            *
+*                                                                                            *
+* return: byte - '-' if unchanged, M if modified and D if deleted                            *
+**********************************************************************************************/
+func (repo *GitRepo) WorktreeStatus(entry GitIndexEntry) byte {
+	// Submodules have their own status, they are treated as unchanged
+	if entry.Mode&GIT_MODE_TYPE == GIT_MODE_GITLINK {
+		return '-'
+	}
+
+	absPath := filepath.Join(repo.Root, filepath.FromSlash(entry.Path))
+	info, err := os.Lstat(absPath)
+	if err != nil {
+		return 'D'
+	}
+
+	var content []byte
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if entry.Mode&GIT_MODE_TYPE != GIT_MODE_SYMLINK {
+			return 'M'
+		}
+		target, err := os.Readlink(absPath)
+		if err != nil {
+			return 'M'
+		}
+		content = []byte(target)
+	} else if info.Mode().IsRegular() {
+		if entry.Mode&GIT_MODE_TYPE != GIT_MODE_FILE || (info.Mode()&0100 != 0) != (entry.Mode&0100 != 0) {
+			return 'M'
+		}
+		if uint32(info.Size()) != entry.Size {
+			return 'M'
+		}
+		if modTime := info.ModTime(); uint32(modTime.Unix()) == entry.MSec && uint32(modTime.Nanosecond()) == entry.MNsec {
+			return '-'
+		}
+
+		content, err = os.ReadFile(absPath)
+		if err != nil {
+			return 'M'
+		}
+	} else {
+		return 'M'
+	}
+
+	if HashGitBlob(content) != entry.Hash {
+		return 'M'
+	}
+	return '-'
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: DirStatus                                                                            *
+*                                                                                            *
+* Description: Combines the status of everything below a directory. Each column shows the    *
+*              most significant change found. Directories without tracked files are          *
+*              ignored, untracked or unchanged if they hold nothing Git would list           *
+*                                                                                            *
+* Parameters: relDir : string - The directory relative to the work tree root                 *
+*                                                                                            *
+* return: GitStatus                                                                          *
+**********************************************************************************************/
+func (repo *GitRepo) DirStatus(relDir string) GitStatus {
+	if status, ok := repo.statuses[relDir+"/"]; ok {
+		return status
+	}
+
+	absDir := filepath.Join(repo.Root, filepath.FromSlash(relDir))
+	status := GitStatus{'-', '-'}
+
+	prefix := relDir + "/"
+	if relDir == "." {
+		prefix = ""
+	}
+
+	// The index and HEAD paths below the directory are next to each other once sorted
+	start := sort.SearchStrings(repo.paths, prefix)
+	tracked := false
+	for idx := start; idx < len(repo.paths) && strings.HasPrefix(repo.paths[idx], prefix); idx++ {
+		tracked = true
+		status = status.Combine(repo.FileStatus(repo.paths[idx]))
+	}
+
+	if !tracked && repo.ignore != nil && repo.ignore.IsIgnored(absDir, true) {
+		status = GitStatus{'!', '!'}
+	} else if repo.HasUntracked(absDir) {
+		status = status.Combine(GitStatus{'?', '?'})
+	}
+
+	repo.statuses[relDir+"/"] = status
+	return status
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: HasUntracked                                                                         *
+*                                                                                            *
+* Description: Walks a directory looking for a file that is neither in the index nor         *
+*              ignored. Ignored directories and nested repositories are not entered          *
+*                                                                                            *
+* Parameters: absDir : string - Absolute path of the directory                               *
+*                                                                                            *
+* return: bool                                                                               *
+**********************************************************************************************/
+func (repo *GitRepo) HasUntracked(absDir string) bool {
+	found := errors.New("found")
+	err := filepath.WalkDir(absDir, func(walkPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		if entry.IsDir() {
+			if walkPath == absDir {
+				return nil
+			}
+			if entry.Name() == ".git" || (repo.ignore != nil && repo.ignore.IsIgnored(walkPath, true)) {
+				return filepath.SkipDir
+			}
+			if _, err := os.Lstat(filepath.Join(walkPath, ".git")); err == nil {
+				relPath, _ := filepath.Rel(repo.Root, walkPath)
+				if _, ok := repo.index[filepath.ToSlash(relPath)]; !ok {
+					return found
+				}
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		relPath, _ := filepath.Rel(repo.Root, walkPath)
+		if _, ok := repo.index[filepath.ToSlash(relPath)]; ok {
+			return nil
+		}
+		if repo.ignore != nil && repo.ignore.IsIgnored(walkPath, false) {
+			return nil
+		}
+		return found
+	})
+
+	return err == found
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: Combine                                                                              *
+*                                                                                            *
+* Description: Merges two statuses column by column, keeping the more significant change     *
+*                                                                                            *
+* Parameters: other : GitStatus - The status to merge in                                     *
+*                                                                                            *
+* return: GitStatus - the combined status                                                    *
+**********************************************************************************************/
+func (status GitStatus) Combine(other GitStatus) GitStatus {
+	// Later characters win, ignored files do not count towards a directory's status
+	const significance = "-!?RDAMU"
+	if other.Index != '!' && strings.IndexByte(significance, other.Index) > strings.IndexByte(significance, status.Index) {
+		status.Index = other.Index
+	}
+	if other.Worktree != '!' && strings.IndexByte(significance, other.Worktree) > strings.IndexByte(significance, status.Worktree) {
+		status.Worktree = other.Worktree
+	}
+
+	return status
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: IsChanged                                                                            *
+*                                                                                            *
+* Description: Reports whether the status shows any change, untracked files included         *
+*                                                                                            *
+* Parameters: none                                                                           *
+*                                                                                            *
+* return: bool                                                                               *
+**********************************************************************************************/
+func (status GitStatus) IsChanged() bool {
+	return (status.Index != '-' && status.Index != '!') || (status.Worktree != '-' && status.Worktree != '!')
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetColorGitStatus                                                                    *
+*                                                                                            *
+* Description: Returns the two status characters colored the way git status colors them:     *
+*              staged changes green, unstaged and untracked changes red, ignored files grey  *
+*                                                                                            *
+* Parameters: status : GitStatus - The status to print                                       *
+*                                                                                            *
+* return: string                                                                             *
+**********************************************************************************************/
+func GetColorGitStatus(status GitStatus) string {
+	colorChar := func(char byte, color string) string {
+		switch char {
+		case '-':
+			return string(char)
+		case '!':
+			return GREY + string(char) + RESET
+		case '?', 'U':
+			return RED + string(char) + RESET
+		}
+		return color + string(char) + RESET
+	}
+
+	return colorChar(status.Index, GREEN) + colorChar(status.Worktree, RED)
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetGitColorFilename                                                                  *
+*                                                                                            *
+* Description: Colors a filename with GetColorFilename, but highlights regular files with    *
+*              changes in the color of their status so they stand out in the listing         *
+*                                                                                            *
+* Parameters:  fileinfo : fs.FileInfo - The file to return a colored name for                *
+*              name : string          - The name to print, as returned by FormatFilename     *
+*              status : GitStatus     - The Git status of the file                           *
+*                                                                                            *
+* return: string                                                                             *
+**********************************************************************************************/
+func GetGitColorFilename(fileinfo fs.FileInfo, name string, status GitStatus) string {
+	if fileinfo.IsDir() || !status.IsChanged() {
+		return GetColorFilename(fileinfo, name)
+	}
+
+	if status.Worktree != '-' {
+		return BOLD + YELLOW + name + RESET
+	}
+	return YELLOW + name + RESET
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: HashGitBlob                                                                          *
+*                                                                                            *
+* Description: Returns the object name Git gives a blob with the given content               *
+*                                                                                            *
+* Parameters: content : []byte - The content of the file                                     *
+*                                                                                            *
+* return: [20]byte - the object name                                                         *
+**********************************************************************************************/
+func HashGitBlob(content []byte) [20]byte {
+	hasher := sha1.New()
+	fmt.Fprintf(hasher, "blob %d\x00", len(content))
+	hasher.Write(content)
+
+	var hash [20]byte
+	copy(hash[:], hasher.Sum(nil))
+	return hash
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: ReadObject                                                                           *
+*                                                                                            *
+* Description: Reads an object from the loose object directories or the pack files           *
+*                                                                                            *
+* Parameters: hash : [20]byte - The object name                                              *
+*                                                                                            *
+* return: int    - the object type, one of the GIT_OBJ constants                             *
+*         []byte - the object content                                                        *
+*         error  - non-nil if the object does not exist or could not be read                 *
+**********************************************************************************************/
+func (repo *GitRepo) ReadObject(hash [20]byte) (int, []byte, error) {
+	hexHash := hex.EncodeToString(hash[:])
+	for _, objectDir := range repo.objectDirs {
+		objectType, content, err := ReadLooseObject(filepath.Join(objectDir, hexHash[:2], hexHash[2:]))
+		if !errors.Is(err, fs.ErrNotExist) {
+			return objectType, content, err
+		}
+	}
+
+	for _, pack := range repo.packs {
+		if offset, ok := pack.Find(hash); ok {
+			return repo.ReadPackedObject(pack, offset)
+		}
+	}
+
+	return 0, nil, fmt.Errorf("object %s not found", hexHash)
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: ReadLooseObject                                                                      *
+*                                                                                            *
+* Description: Reads a zlib compressed loose object and splits off its "type size" header    *
+*                                                                                            *
+* Parameters: objectFile : string - The path of the object file                              *
+*                                                                                            *
+* return: int    - the object type                                                           *
+*         []byte - the object content                                                        *
+*         error  - fs.ErrNotExist if there is no such loose object                           *
+**********************************************************************************************/
+func ReadLooseObject(objectFile string) (int, []byte, error) {
+	file, err := os.Open(objectFile)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.Close()
+
+	reader, err := zlib.NewReader(file)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	nul := bytes.IndexByte(data, 0)
+	if nul < 0 {
+		return 0, nil, fmt.Errorf("loose object %s is corrupt", objectFile)
+	}
+
+	typeName, _, _ := strings.Cut(string(data[:nul]), " ")
+	objectTypes := map[string]int{"commit": GIT_OBJ_COMMIT, "tree": GIT_OBJ_TREE, "blob": GIT_OBJ_BLOB, "tag": GIT_OBJ_TAG}
+	return objectTypes[typeName], data[nul+1:], nil
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: OpenGitPacks                                                                         *
+*                                                                                            *
+* Description: Opens every pack file in a directory along with its version 2 index           *
+*                                                                                            *
+* Parameters: packDir : string - The objects/pack directory                                  *
+*                                                                                            *
+* return: []*GitPack - the opened packs                                                      *
+*         error      - non-nil if a pack index could not be read                             *
+**********************************************************************************************/
+func OpenGitPacks(packDir string) ([]*GitPack, error) {
+	idxFiles, _ := filepath.Glob(filepath.Join(packDir, "*.idx"))
+
+	packs := make([]*GitPack, 0, len(idxFiles))
+	for _, idxFile := range idxFiles {
+		data, err := os.ReadFile(idxFile)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) < 8+256*4 || string(data[:4]) != "\377tOc" || binary.BigEndian.Uint32(data[4:8]) != 2 {
+			return nil, fmt.Errorf("pack index %s is not a version 2 index", idxFile)
+		}
+
+		pack := &GitPack{}
+		for idx := range pack.fanout {
+			pack.fanout[idx] = binary.BigEndian.Uint32(data[8+idx*4:])
+		}
+
+		count := int(pack.fanout[255])
+		namesStart := 8 + 256*4
+		offsetsStart := namesStart + count*20 + count*4
+		largeStart := offsetsStart + count*4
+		if len(data) < largeStart {
+			return nil, fmt.Errorf("pack index %s is truncated", idxFile)
+		}
+
+		pack.names = data[namesStart : namesStart+count*20]
+		pack.offsets = make([]uint64, count)
+		for idx := 0; idx < count; idx++ {
+			offset := binary.BigEndian.Uint32(data[offsetsStart+idx*4:])
+			if offset&0x80000000 == 0 {
+				pack.offsets[idx] = uint64(offset)
+				continue
+			}
+
+			// Offsets past 2GiB are stored in a separate table of 64 bit values
+			largeIdx := largeStart + int(offset&0x7fffffff)*8
+			if len(data) < largeIdx+8 {
+				return nil, fmt.Errorf("pack index %s is truncated", idxFile)
+			}
+			pack.offsets[idx] = binary.BigEndian.Uint64(data[largeIdx:])
+		}
+
+		pack.packFile, err = os.Open(strings.TrimSuffix(idxFile, ".idx") + ".pack")
+		if err != nil {
+			return nil, err
+		}
+		packs = append(packs, pack)
+	}
+
+	return packs, nil
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: Find                                                                                 *
+*                                                                                            *
+* Description: Looks an object up in the pack index with a binary search in the range the    *
+*              fanout table gives for its first byte                                         *
+*                                                                                            *
+* Parameters: hash : [20]byte - The object name                                              *
+*                                                                                            *
+* return: uint64 - the offset of the object in the pack file                                 *
+*         bool   - false if the pack does not hold the object                                *
+**********************************************************************************************/
+func (pack *GitPack) Find(hash [20]byte) (uint64, bool) {
+	low := 0
+	if hash[0] > 0 {
+		low = int(pack.fanout[hash[0]-1])
+	}
+	high := int(pack.fanout[hash[0]])
+
+	idx := low + sort.Search(high-low, func(idx int) bool {
+		return bytes.Compare(pack.names[(low+idx)*20:(low+idx+1)*20], hash[:]) >= 0
+	})
+	if idx < high && bytes.Equal(pack.names[idx*20:(idx+1)*20], hash[:]) {
+		return pack.offsets[idx], true
+	}
+
+	return 0, false
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: ReadPackedObject                                                                     *
+*                                                                                            *
+* Description: Reads the object at an offset of a pack file, resolving delta objects against *
+*              their base object                                                             *
+*                                                                                            *
+* Parameters:  pack : *GitPack - The pack holding the object                                 *
+*              offset : uint64 - The offset of the object in the pack file                   *
+*                                                                                            *
+* return: int    - the object type                                                           *
+*         []byte - the object content                                                        *
+*         error  - non-nil if the object could not be read                                   *
+**********************************************************************************************/
+func (repo *GitRepo) ReadPackedObject(pack *GitPack, offset uint64) (int, []byte, error) {
+	reader := bufio.NewReader(io.NewSectionReader(pack.packFile, int64(offset), 1<<62))
+
+	// The header holds the type and the inflated size, 7 bits per byte after the first
+	header, err := reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	objectType := int(header>>4) & 7
+	for header&0x80 != 0 {
+		if header, err = reader.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	var baseType int
+	var base []byte
+	switch objectType {
+	case GIT_OBJ_OFS_DELTA:
+		offsetBytes := make([]byte, 0, 8)
+		for {
+			nextByte, err := reader.ReadByte()
+			if err != nil {
+				return 0, nil, err
+			}
+			offsetBytes = append(offsetBytes, nextByte)
+			if nextByte&0x80 == 0 {
+				break
+			}
+		}
+		distance, _ := ReadGitOffset(offsetBytes)
+		if distance == 0 || distance > offset {
+			return 0, nil, errors.New("pack file has an invalid delta offset")
+		}
+
+		baseType, base, err = repo.ReadPackedObject(pack, offset-distance)
+	case GIT_OBJ_REF_DELTA:
+		var baseHash [20]byte
+		if _, err := io.ReadFull(reader, baseHash[:]); err != nil {
+			return 0, nil, err
+		}
+
+		baseType, base, err = repo.ReadObject(baseHash)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	inflater, err := zlib.NewReader(reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer inflater.Close()
+
+	content, err := io.ReadAll(inflater)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if base != nil {
+		content, err = ApplyGitDelta(base, content)
+		return baseType, content, err
+	}
+	return objectType, content, nil
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: ApplyGitDelta                                                                        *
+*                                                                                            *
+* Description: Rebuilds an object from its base and a pack delta, which is a list of copy    *
+*              from base and insert literal instructions                                     *
+*                                                                                            *
+* Parameters:  base : []byte  - The content of the base object                               *
+*              delta : []byte - The delta instructions                                       *
+*                                                                                            *
+* return: []byte - the rebuilt content                                                       *
+*         error  - non-nil if the delta is corrupt                                           *
+**********************************************************************************************/
+func ApplyGitDelta(base []byte, delta []byte) ([]byte, error) {
+	corrupt := errors.New("pack file has a corrupt delta")
+
+	// The delta starts with the base and result sizes, 7 bits per byte, least significant first
+	readSize := func() (int, bool) {
+		size, shift := 0, 0
+		for len(delta) > 0 {
+			nextByte := delta[0]
+			delta = delta[1:]
+			size |= int(nextByte&0x7f) << shift
+			shift += 7
+			if nextByte&0x80 == 0 {
+				return size, true
+			}
+		}
+		return 0, false
+	}
+
+	baseSize, ok := readSize()
+	if !ok || baseSize != len(base) {
+		return nil, corrupt
+	}
+	resultSize, ok := readSize()
+	if !ok {
+		return nil, corrupt
+	}
+
+	result := make([]byte, 0, resultSize)
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+
+		if op&0x80 == 0 {
+			// Insert the next op bytes as they are
+			if op == 0 || int(op) > len(delta) {
+				return nil, corrupt
+			}
+			result = append(result, delta[:op]...)
+			delta = delta[op:]
+			continue
+		}
+
+		// Copy from the base, the low bits say which offset and size bytes follow
+		var copyOffset, copySize int
+		for bit := 0; bit < 7; bit++ {
+			if op&(1<<bit) == 0 {
+				continue
+			}
+			if len(delta) == 0 {
+				return nil, corrupt
+			}
+			if bit < 4 {
+				copyOffset |= int(delta[0]) << (8 * bit)
+			} else {
+				copySize |= int(delta[0]) << (8 * (bit - 4))
+			}
+			delta = delta[1:]
+		}
+		if copySize == 0 {
+			copySize = 0x10000
+		}
+		if copyOffset+copySize > len(base) {
+			return nil, corrupt
+		}
+		result = append(result, base[copyOffset:copyOffset+copySize]...)
+	}
+
+	if len(result) != resultSize {
+		return nil, corrupt
+	}
+	return result, nil
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Object type names as printed by git cat-file -t
+var gitObjectTypes = map[string]int{
+	"commit": GIT_OBJ_COMMIT,
+	"tree":   GIT_OBJ_TREE,
+	"blob":   GIT_OBJ_BLOB,
+	"tag":    GIT_OBJ_TAG,
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: runGit                                                                               *
+*                                                                                            *
+* Description: Runs git in a directory with the user configuration shut out, failing the test*
+*              if it exits with an error                                                     *
+*                                                                                            *
+* Parameters:  t : *testing.T   - The running test                                           *
+*              dir : string     - The directory to run git in                                *
+*              args : ...string - The git arguments                                          *
+*                                                                                            *
+* return: string - the standard output of git                                                *
+**********************************************************************************************/
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	output, err := gitCommand(dir, args...).Output()
+	if err != nil {
+		t.Fatalf("git %s: %v", strings.Join(args, " "), err)
+	}
+
+	return string(output)
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: gitCommand                                                                           *
+*                                                                                            *
+* Description: Builds a git command that ignores the global and system configuration and     *
+*              commits with a fixed identity                                                 *
+*                                                                                            *
+* Parameters:  dir : string     - The directory to run git in                                *
+*              args : ...string - The git arguments                                          *
+*                                                                                            *
+* return: *exec.Cmd                                                                          *
+**********************************************************************************************/
+func gitCommand(dir string, args ...string) *exec.Cmd {
+	cmd := exec.Command("git", append([]string{"-c", "user.name=vls", "-c", "user.email=vls@example.com"}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL="+os.DevNull, "GIT_CONFIG_NOSYSTEM=1", "HOME="+dir, "XDG_CONFIG_HOME="+dir)
+	return cmd
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: newGitRepo                                                                           *
+*                                                                                            *
+* Description: Creates an empty repository in a temporary directory, skipping the test when  *
+*              git is not installed                                                          *
+*                                                                                            *
+* Parameters: t : *testing.T - The running test                                              *
+*                                                                                            *
+* return: string - the root of the work tree                                                 *
+**********************************************************************************************/
+func newGitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	root := t.TempDir()
+	runGit(t, root, "init", "-q", "-b", "main")
+	return root
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: writeFile                                                                            *
+*                                                                                            *
+* Description: Writes a file below the work tree, creating its parent directories            *
+*                                                                                            *
+* Parameters:  t : *testing.T   - The running test                                           *
+*              root : string    - The root of the work tree                                  *
+*              relPath : string - The slash separated path of the file                       *
+*              content : string - The content to write                                       *
+*                                                                                            *
+* return: none                                                                               *
+**********************************************************************************************/
+func writeFile(t *testing.T, root string, relPath string, content string) {
+	t.Helper()
+	absPath := filepath.Join(root, filepath.FromSlash(relPath))
+	if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(absPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: openRepo                                                                             *
+*                                                                                            *
+* Description: Opens a repository with OpenGitRepo, failing the test on error                *
+*                                                                                            *
+* Parameters:  t : *testing.T - The running test                                             *
+*              root : string  - The root of the work tree                                    *
+*                                                                                            *
+* return: *GitRepo                                                                           *
+**********************************************************************************************/
+func openRepo(t *testing.T, root string) *GitRepo {
+	t.Helper()
+	repo, err := OpenGitRepo(root)
+	if err != nil {
+		t.Fatalf("OpenGitRepo: %v", err)
+	}
+
+	return repo
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: checkStatuses                                                                        *
+*                                                                                            *
+* Description: Compares the status of each path with the two column status expected, paths   *
+*              ending in a slash are looked up with DirStatus                                *
+*                                                                                            *
+* Parameters:  t : *testing.T               - The running test                               *
+*              repo : *GitRepo              - The repository to query                        *
+*              expected : map[string]string - The expected status of each path               *
+*                                                                                            *
+* return: none                                                                               *
+**********************************************************************************************/
+func checkStatuses(t *testing.T, repo *GitRepo, expected map[string]string) {
+	t.Helper()
+	for relPath, want := range expected {
+		var status GitStatus
+		if strings.HasSuffix(relPath, "/") {
+			status = repo.DirStatus(strings.TrimSuffix(relPath, "/"))
+		} else {
+			status = repo.FileStatus(relPath)
+		}
+
+		if got := string([]byte{status.Index, status.Worktree}); got != want {
+			t.Errorf("status of %s = %q, want %q", relPath, got, want)
+		}
+	}
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: setupChangedRepo                                                                     *
+*                                                                                            *
+* Description: Commits a few files and then modifies, stages, renames, deletes and adds files*
+*              so that every kind of change but a conflict is present                        *
+*                                                                                            *
+* Parameters: t : *testing.T - The running test                                              *
+*                                                                                            *
+* return: string - the root of the work tree                                                 *
+**********************************************************************************************/
+func setupChangedRepo(t *testing.T) string {
+	t.Helper()
+	root := newGitRepo(t)
+	writeFile(t, root, ".gitignore", "*.log\nbuild/\n")
+	writeFile(t, root, "clean.txt", "clean\n")
+	writeFile(t, root, "modified.txt", "before\n")
+	writeFile(t, root, "removed.txt", "removed\n")
+	writeFile(t, root, "gone.txt", "gone\n")
+	writeFile(t, root, "old.txt", "content that moves\n")
+	writeFile(t, root, "src/main.c", "int main;\n")
+	writeFile(t, root, "src/util.c", "int util;\n")
+	writeFile(t, root, "docs/readme.txt", "docs\n")
+	writeFile(t, root, "moved/from.txt", "moved within a directory\n")
+	runGit(t, root, "add", "-A")
+	runGit(t, root, "commit", "-q", "-m", "initial")
+
+	// Sizes differ from the committed content so the mtime check cannot hide the change
+	writeFile(t, root, "modified.txt", "after the change\n")
+	writeFile(t, root, "added.txt", "added\n")
+	runGit(t, root, "add", "added.txt")
+	runGit(t, root, "mv", "old.txt", "new.txt")
+	runGit(t, root, "mv", "moved/from.txt", "moved/to.txt")
+	runGit(t, root, "rm", "-q", "removed.txt")
+	if err := os.Remove(filepath.Join(root, "gone.txt")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, root, "untracked.txt", "untracked\n")
+	writeFile(t, root, "debug.log", "ignored\n")
+	writeFile(t, root, "build/out.o", "ignored\n")
+	writeFile(t, root, "src/util.c", "int util = 1;\n")
+	writeFile(t, root, "docs/new.txt", "untracked\n")
+	writeFile(t, root, "docs/trace.log", "ignored\n")
+
+	return root
+}
+
+// The statuses of the paths set up by setupChangedRepo, as git status --short prints them
+var changedRepoStatuses = map[string]string{
+	"clean.txt":       "--",
+	"modified.txt":    "-M",
+	"added.txt":       "A-",
+	"new.txt":         "R-",
+	"old.txt":         "R-",
+	"removed.txt":     "D-",
+	"gone.txt":        "-D",
+	"untracked.txt":   "??",
+	"debug.log":       "!!",
+	"build/out.o":     "!!",
+	"src/main.c":      "--",
+	"src/util.c":      "-M",
+	"docs/readme.txt": "--",
+	"docs/new.txt":    "??",
+	"docs/trace.log":  "!!",
+	"moved/to.txt":    "R-",
+	"src/":            "-M",
+	"docs/":           "??",
+	"moved/":          "R-",
+	"build/":          "!!",
+	"./":              "AM",
+}
+
+func TestFileStatus(t *testing.T) {
+	root := setupChangedRepo(t)
+	checkStatuses(t, openRepo(t, root), changedRepoStatuses)
+}
+
+func TestFileStatusConflict(t *testing.T) {
+	root := newGitRepo(t)
+	writeFile(t, root, "conflict.txt", "base\n")
+	writeFile(t, root, "other.txt", "other\n")
+	runGit(t, root, "add", "-A")
+	runGit(t, root, "commit", "-q", "-m", "base")
+
+	runGit(t, root, "checkout", "-q", "-b", "topic")
+	writeFile(t, root, "conflict.txt", "topic side\n")
+	runGit(t, root, "commit", "-q", "-a", "-m", "topic")
+
+	runGit(t, root, "checkout", "-q", "main")
+	writeFile(t, root, "conflict.txt", "main side\n")
+	runGit(t, root, "commit", "-q", "-a", "-m", "main")
+
+	// The merge is expected to stop on the conflict
+	if err := gitCommand(root, "merge", "-q", "topic").Run(); err == nil {
+		t.Fatal("git merge succeeded, expected a conflict")
+	}
+
+	checkStatuses(t, openRepo(t, root), map[string]string{
+		"conflict.txt": "UU",
+		"other.txt":    "--",
+		"./":           "UU",
+	})
+}
+
+func TestFileStatusIndexVersions(t *testing.T) {
+	for _, version := range []string{"2", "3", "4"} {
+		t.Run("v"+version, func(t *testing.T) {
+			root := setupChangedRepo(t)
+			runGit(t, root, "update-index", "--index-version", version)
+			checkStatuses(t, openRepo(t, root), changedRepoStatuses)
+		})
+	}
+}
+
+func TestFileStatusPacked(t *testing.T) {
+	repacks := map[string][]string{
+		"gc":        {"gc", "-q"},
+		"ref-delta": {"-c", "repack.useDeltaBaseOffset=false", "repack", "-a", "-d", "-f", "-q"},
+	}
+
+	for name, repack := range repacks {
+		t.Run(name, func(t *testing.T) {
+			root := setupChangedRepo(t)
+			runGit(t, root, repack...)
+			if matches, _ := filepath.Glob(filepath.Join(root, ".git", "objects", "pack", "*.pack")); len(matches) == 0 {
+				t.Fatal("no pack file was written")
+			}
+			checkStatuses(t, openRepo(t, root), changedRepoStatuses)
+		})
+	}
+}
+
+func TestReadObjectDeltas(t *testing.T) {
+	root := newGitRepo(t)
+
+	// Successive versions of a large file are stored as deltas of each other once packed
+	var content strings.Builder
+	for line := 0; line < 200; line++ {
+		content.WriteString(strings.Repeat("line of text ", 4) + "\n")
+	}
+	for commit := 0; commit < 5; commit++ {
+		content.WriteString("appended in commit " + string(rune('a'+commit)) + "\n")
+		writeFile(t, root, "big.txt", content.String())
+		runGit(t, root, "add", "big.txt")
+		runGit(t, root, "commit", "-q", "-m", "version")
+	}
+
+	repacks := map[string][]string{
+		"ofs-delta": {"repack", "-a", "-d", "-f", "-q"},
+		"ref-delta": {"-c", "repack.useDeltaBaseOffset=false", "repack", "-a", "-d", "-f", "-q"},
+	}
+
+	for name, repack := range repacks {
+		t.Run(name, func(t *testing.T) {
+			runGit(t, root, repack...)
+
+			packs, _ := filepath.Glob(filepath.Join(root, ".git", "objects", "pack", "*.pack"))
+			if len(packs) != 1 {
+				t.Fatalf("found %d pack files, want 1", len(packs))
+			}
+			if verify := runGit(t, root, "verify-pack", "-v", packs[0]); !strings.Contains(verify, "chain length") {
+				t.Fatal("the pack holds no deltas")
+			}
+
+			repo := openRepo(t, root)
+			for _, line := range strings.Split(strings.TrimSpace(runGit(t, root, "rev-list", "--objects", "--all")), "\n") {
+				hexHash := strings.Fields(line)[0]
+				hash, err := ParseGitHash(hexHash)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				objectType, content, err := repo.ReadObject(hash)
+				if err != nil {
+					t.Errorf("ReadObject(%s): %v", hexHash, err)
+					continue
+				}
+				typeName := strings.TrimSpace(runGit(t, root, "cat-file", "-t", hexHash))
+				if want := gitObjectTypes[typeName]; objectType != want {
+					t.Errorf("type of %s = %d, want %d", hexHash, objectType, want)
+				}
+				if want := runGit(t, root, "cat-file", typeName, hexHash); !bytes.Equal(content, []byte(want)) {
+					t.Errorf("content of %s differs from git cat-file", hexHash)
+				}
+			}
+		})
+	}
+}
+
+func TestCombine(t *testing.T) {
+	tests := []struct {
+		status GitStatus
+		other  GitStatus
+		want   GitStatus
+	}{
+		{GitStatus{'-', '-'}, GitStatus{'-', 'M'}, GitStatus{'-', 'M'}},
+		{GitStatus{'-', 'M'}, GitStatus{'?', '?'}, GitStatus{'?', 'M'}},
+		{GitStatus{'R', '-'}, GitStatus{'D', '-'}, GitStatus{'D', '-'}},
+		{GitStatus{'A', '-'}, GitStatus{'R', '-'}, GitStatus{'A', '-'}},
+		{GitStatus{'M', 'M'}, GitStatus{'U', 'U'}, GitStatus{'U', 'U'}},
+		{GitStatus{'-', '-'}, GitStatus{'!', '!'}, GitStatus{'-', '-'}},
+	}
+
+	for _, test := range tests {
+		if got := test.status.Combine(test.other); got != test.want {
+			t.Errorf("%q.Combine(%q) = %q, want %q", []byte{test.status.Index, test.status.Worktree},
+				[]byte{test.other.Index, test.other.Worktree}, []byte{got.Index, got.Worktree}, []byte{test.want.Index, test.want.Worktree})
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Starts and ends the OSC 8 escape sequences terminals turn into links
+const (
+	LINK_START = "\033]8;;"
+	LINK_END   = "\033\\"
+)
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetFileURL                                                                           *
+*                                                                                            *
+* Description: Returns the file:// URL of a file, with the host name so the link is not      *
+*              opened on another machine. Every byte other than letters, digits, / and -._~  *
+*              is percent-encoded                                                            *
+*                                                                                            *
+* Parameters:  host : string     - The host name of the machine                              *
+*              filePath : string - The path of the file, made absolute                       *
+*                                                                                            *
+* return: string - the URL                                                                   *
+**********************************************************************************************/
+func GetFileURL(host string, filePath string) string {
+	if absPath, err := filepath.Abs(filePath); err == nil {
+		filePath = absPath
+	}
+
+	var url strings.Builder
+	url.WriteString("file://" + host)
+	for _, b := range []byte(filePath) {
+		if b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || strings.IndexByte("/-._~", b) >= 0 {
+			url.WriteByte(b)
+		} else {
+			fmt.Fprintf(&url, "%%%02X", b)
+		}
+	}
+
+	return url.String()
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: FormatHyperlink                                                                      *
+*                                                                                            *
+* Description: Makes the printed name of a file an OSC 8 link to it with --hyperlink, so it  *
+*              can be clicked in terminals that support them. The escape sequences take up no*
+*              columns, see VisibleWidth                                                     *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags - The command line arguments for the program               *
+*              filePath : string  - The path of the file                                     *
+*              text : string      - The name as printed, with quotes and colors              *
+*                                                                                            *
+* return: string - the name wrapped in a link, or text as it is without --hyperlink          *
+**********************************************************************************************/
+func FormatHyperlink(ArgsFlags *Flags, filePath string, text string) string {
+	if !ArgsFlags.Hyperlinks {
+		return text
+	}
+
+	return LINK_START + GetFileURL(ArgsFlags.LinkHost, filePath) + LINK_END + text + LINK_START + LINK_END
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Nerd Font glyphs for files with well known names, looked up first
+var iconsByName = map[string]string{
+	"Makefile":           "\ue779",
+	"makefile":           "\ue779",
+	"go.mod":             "\ue627",
+	"go.sum":             "\ue627",
+	"Dockerfile":         "\uf308",
+	"docker-compose.yml": "\uf308",
+	".dockerignore":      "\uf308",
+	".gitignore":         "\uf1d3",
+	".gitattributes":     "\uf1d3",
+	".gitmodules":        "\uf1d3",
+	".git":               "\ue5fb",
+	"node_modules":       "\ue5fa",
+	"LICENSE":            "\uf0e3",
+	"README.md":          "\uf48a",
+	".bashrc":            "\uf489",
+	".zshrc":             "\uf489",
+	".profile":           "\uf489",
+}
+
+// Glyphs for the files that are not regular, and for regular files no other table names
+var iconsByType = map[string]string{
+	"dir":    "\uf07b",
+	"link":   "\uf0c1",
+	"pipe":   "\uf0ec",
+	"socket": "\uf1e6",
+	"device": "\uf0a0",
+	"exec":   "\uf489",
+	"file":   "\uf15b",
+}
+
+// Glyphs for regular files by extension, compared without case
+var iconsByExt = map[string]string{
+	"go":    "\ue627",
+	"c":     "\ue61e",
+	"h":     "\uf0fd",
+	"cpp":   "\ue61d",
+	"rs":    "\ue7a8",
+	"py":    "\ue606",
+	"js":    "\ue74e",
+	"ts":    "\ue628",
+	"java":  "\ue738",
+	"rb":    "\ue739",
+	"sh":    "\uf489",
+	"vim":   "\ue62b",
+	"md":    "\uf48a",
+	"txt":   "\uf15c",
+	"log":   "\uf18d",
+	"json":  "\ue60b",
+	"yml":   "\uf481",
+	"yaml":  "\uf481",
+	"toml":  "\ue615",
+	"html":  "\uf13b",
+	"css":   "\ue749",
+	"csv":   "\uf1c3",
+	"sql":   "\uf1c0",
+	"pdf":   "\uf1c1",
+	"diff":  "\uf440",
+	"patch": "\uf440",
+	"lock":  "\uf023",
+	"zip":   "\uf410",
+	"tar":   "\uf410",
+	"gz":    "\uf410",
+	"xz":    "\uf410",
+	"bz2":   "\uf410",
+	"7z":    "\uf410",
+	"png":   "\uf1c5",
+	"jpg":   "\uf1c5",
+	"jpeg":  "\uf1c5",
+	"gif":   "\uf1c5",
+	"svg":   "\uf1c5",
+	"mp3":   "\uf001",
+	"flac":  "\uf001",
+	"wav":   "\uf001",
+	"mp4":   "\uf03d",
+	"mkv":   "\uf03d",
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetIconConfigPath                                                                    *
+*                                                                                            *
+* Description: Returns the path of the file that overrides the icon tables, vls/icons in     *
+*              $XDG_CONFIG_HOME or in ~/.config when that is not set                         *
+*                                                                                            *
+* Parameters: none                                                                           *
+*                                                                                            *
+* return: string - the path, empty if the home directory is unknown                          *
+**********************************************************************************************/
+func GetIconConfigPath() string {
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		configDir = filepath.Join(home, ".config")
+	}
+
+	return filepath.Join(configDir, "vls", "icons")
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: LoadIconConfig                                                                       *
+*                                                                                            *
+* Description: Reads the icon config file into the icon tables. Each line is key=glyph, where*
+*              the key is a file name, *.ext for an extension or type:dir, type:link,        *
+*              type:pipe, type:socket, type:device, type:exec or type:file. Empty lines and  *
+*              lines starting with # are skipped, and an empty glyph removes the icon. A     *
+*              missing file is not an error                                                  *
+*                                                                                            *
+* Parameters: path : string - The path of the config file                                    *
+*                                                                                            *
+* return: error - non-nil if the file cannot be read or has an invalid line                  *
+**********************************************************************************************/
+func LoadIconConfig(path string) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, glyph, found := strings.Cut(line, "=")
+		key, glyph = strings.TrimSpace(key), strings.TrimSpace(glyph)
+		if !found || key == "" {
+			return fmt.Errorf("%s:%d: expected key=glyph", path, lineNum)
+		}
+
+		switch {
+		case strings.HasPrefix(key, "type:"):
+			if _, ok := iconsByType[key[len("type:"):]]; !ok {
+				return fmt.Errorf("%s:%d: unknown file type %q", path, lineNum, key[len("type:"):])
+			}
+			iconsByType[key[len("type:"):]] = glyph
+		case strings.HasPrefix(key, "*."):
+			iconsByExt[strings.ToLower(key[len("*."):])] = glyph
+		default:
+			iconsByName[key] = glyph
+		}
+	}
+
+	return scanner.Err()
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetIcon                                                                              *
+*                                                                                            *
+* Description: Returns the glyph printed before a name with --icons. Well known names come   *
+*              first, then the type of files that are not regular, then the extension and    *
+*              last whether the file is executable                                           *
+*                                                                                            *
+* Parameters: fileinfo : fs.FileInfo - The file to return an icon for                        *
+*                                                                                            *
+* return: string - the glyph, empty if the config file removed it                            *
+**********************************************************************************************/
+func GetIcon(fileinfo fs.FileInfo) string {
+	if icon, ok := iconsByName[fileinfo.Name()]; ok {
+		return icon
+	}
+
+	switch GetFileTypeChar(fileinfo.Mode()) {
+	case 'd':
+		return iconsByType["dir"]
+	case 'l':
+		return iconsByType["link"]
+	case 'p':
+		return iconsByType["pipe"]
+	case 's':
+		return iconsByType["socket"]
+	case 'b', 'c':
+		return iconsByType["device"]
+	}
+
+	if icon, ok := iconsByExt[strings.ToLower(GetExtension(fileinfo.Name()))]; ok {
+		return icon
+	} else if fileinfo.Mode()&0111 != 0 {
+		return iconsByType["exec"]
+	}
+	return iconsByType["file"]
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: FormatIcon                                                                           *
+*                                                                                            *
+* Description: Puts the icon of a file in front of its printed name with --icons, separated  *
+*              by a space. The icon gets the color of the name                               *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags     - The command line arguments for the program           *
+*              fileinfo : fs.FileInfo - The file                                             *
+*              name : string          - The name as returned by FormatFilename               *
+*                                                                                            *
+* return: string - the name with its icon, or name as it is without --icons                  *
+**********************************************************************************************/
+func FormatIcon(ArgsFlags *Flags, fileinfo fs.FileInfo, name string) string {
+	if !ArgsFlags.ShowIcons {
+		return name
+	}
+
+	if icon := GetIcon(fileinfo); icon != "" {
+		return icon + " " + name
+	}
+	return name
+}
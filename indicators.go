@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// The values --indicator-style accepts
+var indicatorStyles = []string{"none", "slash", "file-type", "classify"}
+
+// The character appended to a name for each type letter of GetFileTypeChar. Doors, marked with >
+// by ls on Solaris, are not reported by Go
+var typeIndicators = map[byte]string{
+	'd': "/",
+	'l': "@",
+	'p': "|",
+	's': "=",
+}
+
+// A flag that is turned on with always, off with never and by stdout being a terminal with auto.
+// Given without a value it means always, as --classify does in GNU ls
+type WhenFlag string
+
+func (when *WhenFlag) String() string {
+	return string(*when)
+}
+
+func (when *WhenFlag) Set(value string) error {
+	switch value {
+	case "true", "always", "yes", "force":
+		*when = "always"
+	case "false", "never", "no", "none":
+		*when = "never"
+	case "auto", "tty", "if-tty":
+		*when = "auto"
+	default:
+		return fmt.Errorf("invalid value %q, expected always, auto or never", value)
+	}
+	return nil
+}
+
+func (when *WhenFlag) IsBoolFlag() bool {
+	return true
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: Enabled                                                                              *
+*                                                                                            *
+* Description: Tells whether a WhenFlag is turned on. auto is on when stdout is a terminal   *
+*                                                                                            *
+* Parameters: none                                                                           *
+*                                                                                            *
+* return: bool                                                                               *
+**********************************************************************************************/
+func (when *WhenFlag) Enabled() bool {
+	return *when == "always" || (*when == "auto" && IsTerminal(os.Stdout))
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetIndicatorStyle                                                                    *
+*                                                                                            *
+* Description: Decides which names get a type indicator. --indicator-style wins over -F,     *
+*              which wins over --file-type and -p                                            *
+*                                                                                            *
+* Parameters: ArgsFlags : *Flags - The command line arguments for the program                *
+*                                                                                            *
+* return: string - one of indicatorStyles                                                    *
+*         error  - non-nil if the style is unknown                                           *
+**********************************************************************************************/
+func GetIndicatorStyle(ArgsFlags *Flags) (string, error) {
+	switch {
+	case *ArgsFlags.IndicatorStyle != "":
+		for _, known := range indicatorStyles {
+			if *ArgsFlags.IndicatorStyle == known {
+				return known, nil
+			}
+		}
+		return "", fmt.Errorf("invalid indicator style %q, expected none, slash, file-type or classify", *ArgsFlags.IndicatorStyle)
+	case ArgsFlags.Classify.Enabled():
+		return "classify", nil
+	case *ArgsFlags.FileType:
+		return "file-type", nil
+	case *ArgsFlags.SlashDirs:
+		return "slash", nil
+	}
+
+	return "none", nil
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetIndicator                                                                         *
+*                                                                                            *
+* Description: Returns the character appended to a name for the type of the file: / for      *
+*              directories, @ for symbolic links, | for named pipes, = for sockets and * for *
+*              executables. It is printed after the color codes so it is never colored       *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags     - The command line arguments for the program           *
+*              fileinfo : fs.FileInfo - The file to return an indicator for                  *
+*                                                                                            *
+* return: string - the indicator, empty if there is none                                     *
+**********************************************************************************************/
+func GetIndicator(ArgsFlags *Flags, fileinfo fs.FileInfo) string {
+	typeChar := GetFileTypeChar(fileinfo.Mode())
+
+	switch ArgsFlags.Indicators {
+	case "slash":
+		if typeChar == 'd' {
+			return "/"
+		}
+	case "file-type":
+		return typeIndicators[typeChar]
+	case "classify":
+		if typeChar == 'f' && fileinfo.Mode()&0111 != 0 {
+			return "*"
+		}
+		return typeIndicators[typeChar]
+	}
+
+	return ""
+}
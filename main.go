@@ -1,42 +1,186 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io/fs"
+	"math"
 	"os"
 	"os/user"
+	"path"
 	"sort"
+	"strconv"
 	"strings"
 	"syscall"
+	"text/template"
+	"time"
 )
 
 // Terminal color codes
 const (
-	RESET  = "\033[0m"
-	BOLD   = "\033[1m"
-	RED    = "\033[31m"
-	GREEN  = "\033[32m"
-	YELLOW = "\033[33m"
-	BLUE   = "\033[34m"
-	PURPLE = "\033[35m"
-	CYAN   = "\033[36m"
-	GREY   = "\033[37m"
+	RESET     = "\033[0m"
+	BOLD      = "\033[1m"
+	UNDERLINE = "\033[4m"
+	RED       = "\033[31m"
+	GREEN     = "\033[32m"
+	YELLOW    = "\033[33m"
+	BLUE      = "\033[34m"
+	PURPLE    = "\033[35m"
+	CYAN      = "\033[36m"
+	GREY      = "\033[37m"
 )
 
+// Units used when printing and parsing human readable sizes, each 1024 times the previous
+var sizeUnits = []string{"K", "M", "G", "T", "P"}
+
+// Units accepted in durations such as 7d
+var ageUnits = map[byte]time.Duration{
+	's': time.Second,
+	'm': time.Minute,
+	'h': time.Hour,
+	'd': 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+	'y': 365 * 24 * time.Hour,
+}
+
+// A flag that can be given multiple times, every value is a shell glob matched with path.Match
+type PatternList []string
+
+func (patterns *PatternList) String() string {
+	return strings.Join(*patterns, ",")
+}
+
+func (patterns *PatternList) Set(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %s", pattern, err)
+	}
+	*patterns = append(*patterns, pattern)
+	return nil
+}
+
+// Lists a file under a different name than the one it has on disk, used for the . and .. entries
+type RenamedFileInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (info RenamedFileInfo) Name() string {
+	return info.name
+}
+
+// Identifies a directory by device and inode, the same directory can be reached through several paths
+type DirID struct {
+	Dev uint64
+	Ino uint64
+}
+
+// Directories listed so far, -R does not list a directory twice so symlink loops under -L end
+var listedDirs = map[DirID]bool{}
+
+// Exit status of the program: 1 if a subdirectory could not be read, 2 if a directory was skipped
+// because it was already listed, as GNU ls does
+var exitStatus int
+
+// Signature shared by PrintNormalListing and PrintLongListing, used to recurse with the same format
+type ListingPrinter func(ArgsFlags *Flags, filesInfo []fs.FileInfo, callingDir string, depth int)
+
+// Number of directory listings printed so far, used to separate them with blank lines
+var sectionsListed int
+
+// Ends every line of the normal and long listings, a NUL byte with --zero
+var lineEnd = "\n"
+
+// Starts every line of the long listing, two spaces with --dired as in GNU ls
+var linePrefix = ""
+
+// A labeled section of entries, used when the output is grouped with --group-by
+type FileGroup struct {
+	Label string
+	Files []fs.FileInfo
+	key   string // Orders the sections, see GetGroupKey
+}
+
+// Section labels used by --group-by=type, in the order they are printed
+var typeGroupLabels = []string{"Directories", "Symbolic links", "Executables", "Files", "Special files"}
+
 // Holds the command line arguments, used by printing functions
 // to determine how to print output
 type Flags struct {
-	LongListing   *bool
-	HumanReadable *bool
-	Recursive     *bool
-	SortTime      *bool
-	SortSize      *bool
-	Reverse       *bool
-	NoColors      *bool
-	ShowHidden    *bool
-	ShowINodes    *bool
-	Path          string
+	LongListing    *bool
+	HumanReadable  *bool
+	Recursive      *bool
+	SortTime       *bool
+	SortSize       *bool
+	SortExtension  *bool
+	SortBy         *string
+	Reverse        *bool
+	NoColors       *bool
+	QuotingStyle   *string
+	QuoteName      *bool
+	Escape         *bool
+	HideControl    *bool
+	ShowControl    *bool
+	Classify       *WhenFlag
+	SlashDirs      *bool
+	FileType       *bool
+	IndicatorStyle *string
+	Hyperlink      *WhenFlag
+	Icons          *WhenFlag
+	Zero           *bool
+	Dired          *bool
+	FilesFrom      *string
+	Files0From     *string
+	Dereference    *bool
+	ShowHidden     *bool
+	AlmostAll      *bool
+	IgnoreBackups  *bool
+	Ignore         *PatternList
+	Hide           *PatternList
+	GitIgnore      *bool
+	Where          *string
+	Types          *string
+	MinSize        *string
+	MaxSize        *string
+	NewerThan      *string
+	OlderThan      *string
+	MaxDepth       *int
+	MinDepth       *int
+	OneFileSystem  *bool
+	ExcludeDirs    *PatternList
+	ShowINodes     *bool
+	ShowExtension  *bool
+	ShowGit        *bool
+	DirSize        *bool
+	Bars           *bool
+	Summary        *bool
+	Columns        *string
+	Header         *bool
+	Format         *string
+	Template       *string
+	TemplateFile   *string
+	TemplateHeader *string
+	TemplateFooter *string
+	Printf         *string
+
+	GroupDirsFirst *bool
+	GroupBy        *string
+	Path           string
+	WhereExpr      *WhereNode         // The parsed --where expression, nil if none was given
+	MinBytes       int64              // The parsed --min-size, 0 if none was given
+	MaxBytes       int64              // The parsed --max-size, -1 if none was given
+	NewerTime      time.Time          // The parsed --newer-than, zero if none was given
+	OlderTime      time.Time          // The parsed --older-than, zero if none was given
+	StartDevice    uint64             // Device of the listed directory, used by -x
+	LongColumns    []Column           // The columns of the long listing format, see GetLongColumns
+	TimeFormat     string             // Layout of the time columns, ISO 8601 in the machine readable formats
+	EntryTemplate  *template.Template // The parsed --template, nil if none was given
+	PrintfFormat   []PrintfDirective  // The parsed --printf, nil if none was given
+	Quoting        string             // The quoting style names are printed with, see GetQuotingStyle
+	Indicators     string             // Which names get a type indicator, see GetIndicatorStyle
+	Hyperlinks     bool               // Whether names are printed as OSC 8 links, see FormatHyperlink
+	LinkHost       string             // The host name in the file:// URLs of --hyperlink
+	ShowIcons      bool               // Whether names are printed after an icon, see FormatIcon
 }
 
 func main() {
@@ -44,13 +188,57 @@ func main() {
 	// DebugArgs(ArgsFlags)
 	// fmt.Println()
 
-	// Get the files in the calling directory
-	filesInfo := GetFilesInfo(ArgsFlags.Path)
-	if *ArgsFlags.LongListing {
-		PrintLongListing(ArgsFlags, filesInfo, ArgsFlags.Path, false)
+	// Get the files in the calling directory, or the files named in --files-from
+	var filesInfo []fs.FileInfo
+	var err error
+	startDir := ArgsFlags.Path
+	if *ArgsFlags.FilesFrom != "" || *ArgsFlags.Files0From != "" {
+		startDir = FILES_FROM_DIR
+		filesInfo, err = GetGivenFilesInfo(ArgsFlags)
+		if err != nil {
+			fmt.Printf("Error reading the list of files: %s\n", err)
+			os.Exit(1)
+		}
 	} else {
-		PrintNormalListing(ArgsFlags, filesInfo, ArgsFlags.Path, false)
+		filesInfo, err = GetFilesInfo(ArgsFlags, ArgsFlags.Path)
+		if err != nil {
+			fmt.Printf("Error reading directory: %s\n", err)
+			os.Exit(1)
+		}
+
+		// -R never lists the calling directory a second time
+		if startInfo, err := os.Stat(ArgsFlags.Path); err == nil {
+			MarkDirListed(startInfo)
+		}
+	}
+
+	switch {
+	case ArgsFlags.PrintfFormat != nil:
+		PrintPrintfListing(ArgsFlags, filesInfo, startDir, 0)
+	case ArgsFlags.EntryTemplate != nil:
+		PrintTemplateListing(ArgsFlags, filesInfo, startDir, 0)
+	case *ArgsFlags.Format == "html":
+		PrintHTMLListing(ArgsFlags, filesInfo, startDir, 0)
+		PrintHTMLReport(ArgsFlags)
+	case *ArgsFlags.Format == "markdown":
+		PrintMarkdownListing(ArgsFlags, filesInfo, startDir, 0)
+	case *ArgsFlags.Format != "":
+		PrintMachineListing(ArgsFlags, filesInfo, startDir, 0)
+		FlushMachineListing()
+	case *ArgsFlags.LongListing:
+		PrintLongListing(ArgsFlags, filesInfo, startDir, 0)
+	default:
+		PrintNormalListing(ArgsFlags, filesInfo, startDir, 0)
+	}
+
+	if *ArgsFlags.Summary {
+		listingSummary.Print(ArgsFlags)
+	}
+	if *ArgsFlags.Dired {
+		PrintDiredFooter(ArgsFlags)
 	}
+
+	os.Exit(exitStatus)
 }
 
 /*********************************************************************************************
@@ -88,58 +276,279 @@ func ParseArgs() *Flags {
 	ArgsFlags.Recursive = flag.Bool("R", false, "List subdirectories recursively")
 	ArgsFlags.SortTime = flag.Bool("t", false, "Sort by modification time")
 	ArgsFlags.SortSize = flag.Bool("S", false, "Sort by file size")
+	ArgsFlags.SortExtension = flag.Bool("X", false, "Sort alphabetically by file extension")
+	ArgsFlags.SortBy = flag.String("sort", "name", "Sort by `word`: name, size, time or extension")
 	ArgsFlags.Reverse = flag.Bool("r", false, "Reverse the order of sort")
 	ArgsFlags.NoColors = flag.Bool("G", false, "Disable colorized output")
+	ArgsFlags.QuotingStyle = flag.String("quoting-style", "", "Print names in the quoting `style`: "+strings.Join(quotingStyles, ", ")+". Defaults to shell-escape on a terminal")
+	ArgsFlags.QuoteName = flag.Bool("Q", false, "Print names in double quotes with C escapes, same as --quoting-style=c")
+	flag.BoolVar(ArgsFlags.QuoteName, "quote-name", false, "Same as -Q")
+	ArgsFlags.Escape = flag.Bool("b", false, "Print unprintable characters as C escapes, same as --quoting-style=escape")
+	flag.BoolVar(ArgsFlags.Escape, "escape", false, "Same as -b")
+	ArgsFlags.HideControl = flag.Bool("q", false, "Print ? in place of unprintable characters, the default on a terminal")
+	flag.BoolVar(ArgsFlags.HideControl, "hide-control-chars", false, "Same as -q")
+	ArgsFlags.ShowControl = flag.Bool("show-control-chars", false, "Print unprintable characters as they are, the default when stdout is not a terminal")
+	ArgsFlags.Classify = new(WhenFlag)
+	flag.Var(ArgsFlags.Classify, "F", "Append an indicator to names: / directory, * executable, @ link, = socket, | pipe")
+	flag.Var(ArgsFlags.Classify, "classify", "Same as -F, `when` is always, auto or never")
+	ArgsFlags.SlashDirs = flag.Bool("p", false, "Append / to the names of directories")
+	ArgsFlags.FileType = flag.Bool("file-type", false, "Same as -F without * for executables")
+	ArgsFlags.Hyperlink = new(WhenFlag)
+	flag.Var(ArgsFlags.Hyperlink, "hyperlink", "Print names as links that can be clicked in terminals supporting OSC 8, `when` is always, auto or never")
+	ArgsFlags.Icons = new(WhenFlag)
+	flag.Var(ArgsFlags.Icons, "icons", "Print a Nerd Font icon before each name, `when` is always, auto or never")
+	ArgsFlags.IndicatorStyle = flag.String("indicator-style", "", "Append indicators to names in the given `style`: none, slash (-p), file-type (--file-type) or classify (-F)")
+	ArgsFlags.Zero = flag.Bool("zero", false, "End each entry and line with a NUL byte instead of a space or newline, and disable colors")
+	ArgsFlags.Dired = flag.Bool("D", false, "Use long listing format for Emacs dired mode, ending with the byte offsets of the names")
+	flag.BoolVar(ArgsFlags.Dired, "dired", false, "Same as -D")
+	ArgsFlags.FilesFrom = flag.String("files-from", "", "List the paths read from `file`, one per line, instead of a directory. - reads stdin")
+	ArgsFlags.Files0From = flag.String("files0-from", "", "List the paths read from `file`, each ended by a NUL byte, instead of a directory. - reads stdin")
+	ArgsFlags.Dereference = flag.Bool("L", false, "Show the file a symbolic link points to instead of the link, -R follows links to directories")
+	flag.BoolVar(ArgsFlags.Dereference, "dereference", false, "Same as -L")
 
 	// Define flags related to filtering
-	ArgsFlags.ShowHidden = flag.Bool("a", false, "Show hidden files")
+	ArgsFlags.ShowHidden = flag.Bool("a", false, "Show hidden files, including . and ..")
+	ArgsFlags.AlmostAll = flag.Bool("A", false, "Show hidden files except . and ..")
+	flag.BoolVar(ArgsFlags.AlmostAll, "almost-all", false, "Same as -A")
+	ArgsFlags.IgnoreBackups = flag.Bool("B", false, "Do not list files ending with ~")
+	flag.BoolVar(ArgsFlags.IgnoreBackups, "ignore-backups", false, "Same as -B")
+	ArgsFlags.Ignore = &PatternList{}
+	flag.Var(ArgsFlags.Ignore, "I", "Do not list files matching the shell `pattern`, can be repeated")
+	flag.Var(ArgsFlags.Ignore, "ignore", "Same as -I")
+	ArgsFlags.Hide = &PatternList{}
+	flag.Var(ArgsFlags.Hide, "hide", "Do not list files matching the shell `pattern` unless -a or -A is given")
+	ArgsFlags.GitIgnore = flag.Bool("gitignore", false, "Do not list files ignored by Git")
+	ArgsFlags.Where = flag.String("where", "", "Only list files matching the `expression`, such as 'size>10M && mtime<7d'")
+	ArgsFlags.Types = flag.String("type", "", "Only list files of the given `types`, a comma separated list of f, d, l, p, s, b and c")
+	ArgsFlags.MinSize = flag.String("min-size", "", "Only list files of at least `size` bytes, units such as 10K or 1.5G are accepted")
+	ArgsFlags.MaxSize = flag.String("max-size", "", "Only list files of at most `size` bytes, units such as 10K or 1.5G are accepted")
+	ArgsFlags.NewerThan = flag.String("newer-than", "", "Only list files modified within `age` (such as 2h or 30d) or after the given file")
+	ArgsFlags.OlderThan = flag.String("older-than", "", "Only list files not modified within `age` (such as 2h or 30d) or before the given file")
 	ArgsFlags.ShowINodes = flag.Bool("i", false, "Print the inode number of each file")
+	ArgsFlags.ShowGit = flag.Bool("git", false, "Print the Git status of each file in long listing format")
+	ArgsFlags.ShowExtension = flag.Bool("extension-column", false, "Print the extension of each file in long listing format")
+	ArgsFlags.DirSize = flag.Bool("dir-size", false, "Show the total size of the contents of directories, and the disk space used in long listing format")
+	flag.BoolVar(ArgsFlags.DirSize, "du", false, "Same as --dir-size")
+	ArgsFlags.Columns = flag.String("columns", "", "Comma separated `list` of the columns of the long listing format, in order: "+strings.Join(GetColumnNames(), ","))
+	ArgsFlags.Format = flag.String("format", "", "Print the long listing columns in another `format`: csv, tsv, html or markdown")
+	ArgsFlags.Template = flag.String("template", "", "Print each entry with a Go text/`template`, such as '{{.Mode}} {{.Size | human}} {{.Name}}'")
+	ArgsFlags.TemplateFile = flag.String("template-file", "", "Read the --template from a `file`")
+	ArgsFlags.TemplateHeader = flag.String("template-header", "", "Print the `template` before the entries of each directory")
+	ArgsFlags.TemplateFooter = flag.String("template-footer", "", "Print the `template` after the entries of each directory")
+	ArgsFlags.Printf = flag.String("printf", "", "Print each entry with a find style `format`, such as '%m %u %s %TY-%Tm-%Td %p\\n'")
+	ArgsFlags.Header = flag.Bool("header", false, "Print a row with the title of each column in long listing format")
+	ArgsFlags.Summary = flag.Bool("summary", false, "Print counts by type, total sizes and the largest and newest entries after the listing")
+	ArgsFlags.Bars = flag.Bool("bars", false, "Draw a bar showing each entry's share of the total size in long listing format")
+
+	// Define flags related to recursion
+	ArgsFlags.MaxDepth = flag.Int("max-depth", 0, "With -R, do not list entries more than `levels` below the listed directory, 0 for no limit")
+	ArgsFlags.MinDepth = flag.Int("min-depth", 0, "With -R, do not list entries less than `levels` below the listed directory")
+	ArgsFlags.OneFileSystem = flag.Bool("x", false, "With -R, do not descend into directories on other file systems")
+	flag.BoolVar(ArgsFlags.OneFileSystem, "one-file-system", false, "Same as -x")
+	ArgsFlags.ExcludeDirs = &PatternList{}
+	flag.Var(ArgsFlags.ExcludeDirs, "exclude-dir", "With -R, do not descend into directories matching the shell `pattern`, can be repeated")
+
+	// Define flags related to grouping
+	ArgsFlags.GroupDirsFirst = flag.Bool("group-directories-first", false, "List directories before files")
+	ArgsFlags.GroupBy = flag.String("group-by", "", "Print entries in labeled sections by `type`, extension or owner")
+
+	flag.Usage = PrintUsage
+	flag.CommandLine.Parse(ExpandMultiFlags(os.Args[1:]))
+
+	var err error
+	leftover := flag.Args()
+
+	if len(leftover) > 0 && (*ArgsFlags.FilesFrom != "" || *ArgsFlags.Files0From != "") {
+		fmt.Printf("Error parsing args: a path cannot be given along with --files-from or --files0-from\n")
+		os.Exit(1)
+	}
+
+	if len(leftover) == 0 { // Case when vls
+		ArgsFlags.Path, err = os.Getwd()
+	} else if len(leftover) == 1 { // Case when vls <path>
+		ArgsFlags.Path = leftover[0]
+	} else {
+		PrintUsage()
+		os.Exit(1)
+	}
+
+	// Catch errors
+	if err != nil {
+		fmt.Printf("Error parsing args:%s\n", err)
+		PrintUsage()
+		os.Exit(1)
+	}
+
+	// Names are quoted so control characters in them cannot reach the terminal
+	ArgsFlags.Quoting, err = GetQuotingStyle(&ArgsFlags)
+	if err != nil {
+		fmt.Printf("Error parsing args: %s\n", err)
+		os.Exit(1)
+	}
+	if !*ArgsFlags.HideControl && !*ArgsFlags.ShowControl {
+		*ArgsFlags.HideControl = IsTerminal(os.Stdout)
+	}
 
-	argv := os.Args[1:]
-	argc := len(os.Args[1:])
+	ArgsFlags.Indicators, err = GetIndicatorStyle(&ArgsFlags)
+	if err != nil {
+		fmt.Printf("Error parsing args: %s\n", err)
+		os.Exit(1)
+	}
 
-	var err error // Gets reused by both methods of error parsing
+	if ArgsFlags.Hyperlink.Enabled() {
+		ArgsFlags.Hyperlinks = true
+		ArgsFlags.LinkHost, _ = os.Hostname()
+	}
 
-	// Case when vls
-	if argc == 0 {
-		ArgsFlags.Path, err = os.Getwd()
-	} else if argc == 1 {
-		/*
-		   Case when:
-		   vls <path>
-		   vls -l
-		   vls -lah
-		*/
-
-		// Catches multiple argument case
-		if argv[0][0] == '-' && len(argv[0]) > 2 {
-			ParseMultiFlags()
-			ArgsFlags.Path, err = os.Getwd()
-		} else if argv[0][0] == '-' { // Catches the single flag case
-			flag.Parse()
-			ArgsFlags.Path, err = os.Getwd()
-		} else { // Catches the case when just a path is given
-			ArgsFlags.Path = argv[0]
-		}
-
-	} else if argc == 2 { // Catches the case when command is in form vls -lah <path>
-		ParseMultiFlags()
-		ArgsFlags.Path = argv[argc-1]
-	} else { // Catches the case in which more than 1 flag is given seperately
-		flag.Parse()
-		leftover := flag.Args()
-
-		if len(leftover) > 1 {
-			PrintUsage()
+	if ArgsFlags.Icons.Enabled() {
+		ArgsFlags.ShowIcons = true
+		if err = LoadIconConfig(GetIconConfigPath()); err != nil {
+			fmt.Printf("Invalid icon config: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Names are printed as they are, so the output can be split on NUL bytes
+	if *ArgsFlags.Zero {
+		*ArgsFlags.NoColors = true
+		ArgsFlags.Quoting = "literal"
+		ArgsFlags.Hyperlinks = false
+		ArgsFlags.ShowIcons = false
+		*ArgsFlags.HideControl = false
+		lineEnd = "\x00"
+	}
+
+	if *ArgsFlags.Where != "" {
+		ArgsFlags.WhereExpr, err = ParseWhere(*ArgsFlags.Where)
+		if err != nil {
+			fmt.Printf("Invalid --where expression: %s\n", err)
 			os.Exit(1)
 		}
+	}
 
-		ArgsFlags.Path = leftover[0]
+	for _, fileType := range strings.Split(*ArgsFlags.Types, ",") {
+		if *ArgsFlags.Types != "" && (len(fileType) != 1 || !strings.Contains("fdlpsbc", fileType)) {
+			fmt.Printf("Invalid value for --type: %s, expected a comma separated list of f, d, l, p, s, b and c\n", *ArgsFlags.Types)
+			os.Exit(1)
+		}
 	}
 
-	// Catch errors
+	ArgsFlags.MaxBytes = -1
+	if *ArgsFlags.MinSize != "" {
+		ArgsFlags.MinBytes, err = ParseReadableSize(*ArgsFlags.MinSize)
+	}
+	if err == nil && *ArgsFlags.MaxSize != "" {
+		ArgsFlags.MaxBytes, err = ParseReadableSize(*ArgsFlags.MaxSize)
+	}
+	if err == nil && *ArgsFlags.NewerThan != "" {
+		ArgsFlags.NewerTime, err = ParseTimeLimit(*ArgsFlags.NewerThan)
+	}
+	if err == nil && *ArgsFlags.OlderThan != "" {
+		ArgsFlags.OlderTime, err = ParseTimeLimit(*ArgsFlags.OlderThan)
+	}
 	if err != nil {
-		fmt.Printf("Error parsing args:%s\n", err)
+		fmt.Printf("Error parsing args: %s\n", err)
+		os.Exit(1)
+	}
+
+	if *ArgsFlags.MaxDepth < 0 || *ArgsFlags.MinDepth < 0 {
+		fmt.Printf("Invalid depth: --max-depth and --min-depth cannot be negative\n")
+		os.Exit(1)
+	}
+
+	// The device is compared against the subdirectories -x descends into
+	if *ArgsFlags.OneFileSystem {
+		if startInfo, err := os.Stat(ArgsFlags.Path); err == nil {
+			ArgsFlags.StartDevice = GetStatField(startInfo, "dev")
+		}
+	}
+
+	// Machine readable formats hold plain values: no colors or quotes, sizes in bytes and ISO 8601 times.
+	// They escape names in their own way
+	ArgsFlags.TimeFormat = "Jan 02 15:04"
+
+	// The plain text summary lines would end up after the last table row or after </html>
+	if *ArgsFlags.Summary && *ArgsFlags.Format != "" {
+		fmt.Printf("Error parsing args: --summary cannot be used with --format=%s\n", *ArgsFlags.Format)
+		os.Exit(1)
+	}
+
+	switch *ArgsFlags.Format {
+	case "":
+	case "csv", "tsv":
+		*ArgsFlags.NoColors = true
+		ArgsFlags.Quoting = "literal"
+		ArgsFlags.Indicators = "none"
+		ArgsFlags.Hyperlinks = false
+		ArgsFlags.ShowIcons = false
+		*ArgsFlags.HideControl = false
+		*ArgsFlags.HumanReadable = false
+		ArgsFlags.TimeFormat = time.RFC3339
+	case "html", "markdown":
+		*ArgsFlags.NoColors = true
+		ArgsFlags.Quoting = "literal"
+		ArgsFlags.Indicators = "none"
+		ArgsFlags.Hyperlinks = false
+		ArgsFlags.ShowIcons = false
+		*ArgsFlags.HideControl = false
+		ArgsFlags.TimeFormat = "2006-01-02 15:04"
+	default:
+		fmt.Printf("Invalid value for --format: %s\n", *ArgsFlags.Format)
+		PrintUsage()
+		os.Exit(1)
+	}
+
+	// The header and footer are printed around the entries of --template only
+	if (*ArgsFlags.TemplateHeader != "" || *ArgsFlags.TemplateFooter != "") && *ArgsFlags.Template == "" && *ArgsFlags.TemplateFile == "" {
+		fmt.Printf("Error parsing args: --template-header and --template-footer need --template or --template-file\n")
+		os.Exit(1)
+	}
+
+	ArgsFlags.EntryTemplate, err = ParseTemplates(&ArgsFlags)
+	if err != nil {
+		fmt.Printf("Invalid template: %s\n", err)
+		os.Exit(1)
+	}
+
+	if *ArgsFlags.Printf != "" {
+		ArgsFlags.PrintfFormat, err = ParsePrintf(*ArgsFlags.Printf)
+		if err != nil {
+			fmt.Printf("Invalid value for --printf: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Emacs finds the names of --dired by their offsets, so nothing is printed around them
+	if *ArgsFlags.Dired {
+		if *ArgsFlags.Zero || *ArgsFlags.Format != "" || ArgsFlags.EntryTemplate != nil || ArgsFlags.PrintfFormat != nil {
+			fmt.Printf("Error parsing args: --dired cannot be used with --zero, --format, --template or --printf\n")
+			os.Exit(1)
+		}
+		*ArgsFlags.LongListing = true
+		*ArgsFlags.NoColors = true
+		ArgsFlags.Hyperlinks = false
+		ArgsFlags.ShowIcons = false
+		linePrefix = "  "
+	}
+
+	ArgsFlags.LongColumns, err = GetLongColumns(&ArgsFlags)
+	if err != nil {
+		fmt.Printf("Invalid value for --columns: %s\n", err)
+		os.Exit(1)
+	}
+
+	switch *ArgsFlags.SortBy {
+	case "name", "size", "time", "extension":
+	default:
+		fmt.Printf("Invalid value for --sort: %s\n", *ArgsFlags.SortBy)
+		PrintUsage()
+		os.Exit(1)
+	}
+
+	switch *ArgsFlags.GroupBy {
+	case "", "type", "extension", "owner":
+	default:
+		fmt.Printf("Invalid value for --group-by: %s\n", *ArgsFlags.GroupBy)
 		PrintUsage()
 		os.Exit(1)
 	}
@@ -160,22 +569,12 @@ func ParseArgs() *Flags {
 func DebugArgs(ArgsFlags *Flags) {
 	fmt.Println("Path:", ArgsFlags.Path)
 	fmt.Println()
-	// Parse command-line arguments
-	// flag.Parse()
 
 	// Access the values of the flags
-	fmt.Println("Formatting flags:")
-	fmt.Println("-l:", *ArgsFlags.LongListing)
-	fmt.Println("-h:", *ArgsFlags.HumanReadable)
-	fmt.Println("-R:", *ArgsFlags.Recursive)
-	fmt.Println("-t:", *ArgsFlags.SortTime)
-	fmt.Println("-S:", *ArgsFlags.SortSize)
-	fmt.Println("-r:", *ArgsFlags.Reverse)
-	fmt.Println("-G:", *ArgsFlags.NoColors)
-
-	fmt.Println("\nFiltering flags:")
-	fmt.Println("-a:", *ArgsFlags.ShowHidden)
-	fmt.Println("-i:", *ArgsFlags.ShowINodes)
+	fmt.Println("Flags:")
+	flag.VisitAll(func(curFlag *flag.Flag) {
+		fmt.Printf("-%s: %s\n", curFlag.Name, curFlag.Value)
+	})
 
 	// Access non-flag arguments (if any)
 	fmt.Println("\nNon-flag arguments:")
@@ -185,30 +584,79 @@ func DebugArgs(ArgsFlags *Flags) {
 
 /*********************************************************************************************
 *                                                                                            *
-* Name: ParseMultiFlags                                                                      *
+* Name: ExpandMultiFlags                                                                     *
 *                                                                                            *
-* Description: Parses command line arguments when in the form -lahr and sets the flag module *
+* Description: Splits grouped short flags such as -lahr into -l -a -h -r so they can be      *
+*              handed to flag.Parse. A short flag that takes a value uses the rest of its    *
+*              group (-I*.o) or the next argument. Long flags (--name, --name=value) are     *
+*              passed through untouched                                                      *
 *                                                                                            *
-* Parameters: none                                                                           *
+* Parameters: args : []string - The command line arguments without the program name          *
 *                                                                                            *
-* return: none                                                                               *
+* return: []string - The arguments in a form flag.Parse understands                          *
 **********************************************************************************************/
-func ParseMultiFlags() {
-	for i := 1; i < len(os.Args); i++ {
-		arg := os.Args[i]
-		if strings.HasPrefix(arg, "-") {
-			for _, flagChar := range arg[1:] {
-				curFlag := flag.Lookup(string(flagChar))
-				if curFlag == nil {
-					PrintUsage()
-					os.Exit(1)
+func ExpandMultiFlags(args []string) []string {
+	expanded := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		// The first non flag argument ends flag parsing, the rest is passed on untouched
+		if arg == "--" || len(arg) < 2 || arg[0] != '-' {
+			return append(expanded, args[i:]...)
+		}
+
+		// Long flags, the value may be given as the next argument
+		if strings.HasPrefix(arg, "--") {
+			expanded = append(expanded, arg)
+			if !strings.Contains(arg, "=") && TakesValue(arg[2:]) && i+1 < len(args) {
+				i++
+				expanded = append(expanded, args[i])
+			}
+			continue
+		}
+
+		for idx, flagChar := range arg[1:] {
+			name := string(flagChar)
+			if !TakesValue(name) {
+				expanded = append(expanded, "-"+name)
+				continue
+			}
+
+			if rest := arg[1+idx+len(name):]; rest != "" {
+				expanded = append(expanded, "-"+name+"="+rest)
+			} else {
+				expanded = append(expanded, "-"+name)
+				if i+1 < len(args) {
+					i++
+					expanded = append(expanded, args[i])
 				}
-				curFlag.Value.Set("true")
 			}
-		} else {
 			break
 		}
 	}
+
+	return expanded
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: TakesValue                                                                           *
+*                                                                                            *
+* Description: Reports whether the named flag is defined and needs a value, as opposed to    *
+*              boolean flags that are just switched on                                       *
+*                                                                                            *
+* Parameters: name : string - The flag name without dashes                                   *
+*                                                                                            *
+* return: bool                                                                               *
+**********************************************************************************************/
+func TakesValue(name string) bool {
+	curFlag := flag.Lookup(name)
+	if curFlag == nil {
+		return false
+	}
+
+	boolFlag, ok := curFlag.Value.(interface{ IsBoolFlag() bool })
+	return !ok || !boolFlag.IsBoolFlag()
 }
 
 /*********************************************************************************************
@@ -216,30 +664,107 @@ func ParseMultiFlags() {
 * Name: GetFilesInfo                                                                         *
 *                                                                                            *
 * Description: Takes in a string path and returns a slice containing all files in dir        *
-*              contained within that path                                                    *
+*              contained within that path. With -a the . and .. entries are included. Files  *
+*              removed while the directory is read are left out, and with -L symbolic links  *
+*              are replaced by the file they point to unless the link is broken              *
 *                                                                                            *
-* Parameters: path : string - The path to list files and dirs for                            *
+* Parameters:  ArgsFlags : *Flags - The command line arguments for the program               *
+*              path : string      - The path to list files and dirs for                      *
 *                                                                                            *
 * return: []os.FilesInfo                                                                     *
+*         error - non-nil if the directory could not be read                                 *
 **********************************************************************************************/
-func GetFilesInfo(path string) []os.FileInfo {
+func GetFilesInfo(ArgsFlags *Flags, path string) ([]os.FileInfo, error) {
 	files, err := os.ReadDir(path)
 	if err != nil {
-		fmt.Printf("Error reading directory: %s\n", err)
-		os.Exit(1)
+		return nil, err
+	}
+
+	filesInfo := make([]fs.FileInfo, 0, len(files)+2)
+	if *ArgsFlags.ShowHidden && !*ArgsFlags.AlmostAll {
+		filesInfo = append(filesInfo, GetDotEntries(path)...)
 	}
 
-	filesInfo := make([]fs.FileInfo, 0, len(files))
 	for _, file := range files {
 		info, err := file.Info()
-		if err != nil {
-			fmt.Printf("Error: %s", err)
-			os.Exit(1)
+		if errors.Is(err, fs.ErrNotExist) { // Removed or renamed since the directory was read
+			continue
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "vls: %s\n", err)
+			exitStatus = max(exitStatus, 1)
+			continue
+		}
+
+		if *ArgsFlags.Dereference && info.Mode()&fs.ModeSymlink != 0 {
+			if target, err := os.Stat(JoinPath(path, info.Name())); err == nil {
+				info = RenamedFileInfo{target, info.Name()}
+			}
 		}
 		// fmt.Printf("type %T", info)
 		filesInfo = append(filesInfo, info)
 	}
-	return filesInfo
+	return filesInfo, nil
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetDotEntries                                                                        *
+*                                                                                            *
+* Description: Builds the . and .. entries of a directory, which os.ReadDir leaves out, by   *
+*              calling lstat on the directory and its parent                                 *
+*                                                                                            *
+* Parameters: path : string - The directory being listed                                     *
+*                                                                                            *
+* return: []fs.FileInfo - the entries that could be read                                     *
+**********************************************************************************************/
+func GetDotEntries(path string) []fs.FileInfo {
+	dotEntries := make([]fs.FileInfo, 0, 2)
+	for _, name := range []string{".", ".."} {
+		info, err := os.Lstat(JoinPath(path, name))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "vls: %s\n", err)
+			exitStatus = max(exitStatus, 1)
+			continue
+		}
+		dotEntries = append(dotEntries, RenamedFileInfo{info, name})
+	}
+
+	return dotEntries
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: JoinPath                                                                             *
+*                                                                                            *
+* Description: Returns the path of a file in a directory. Entries read with --files-from have*
+*              no directory, their name already is their path                                *
+*                                                                                            *
+* Parameters:  dir : string  - The directory, FILES_FROM_DIR for entries named by their path *
+*              name : string - The name of the file                                          *
+*                                                                                            *
+* return: string - the path                                                                  *
+**********************************************************************************************/
+func JoinPath(dir string, name string) string {
+	if dir == FILES_FROM_DIR {
+		return name
+	}
+
+	return dir + "/" + name
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: IsDotEntry                                                                           *
+*                                                                                            *
+* Description: Reports whether a name is one of the . and .. entries, which are never        *
+*              descended into                                                                *
+*                                                                                            *
+* Parameters: name : string - The filename to test                                           *
+*                                                                                            *
+* return: bool                                                                               *
+**********************************************************************************************/
+func IsDotEntry(name string) bool {
+	return name == "." || name == ".."
 }
 
 /*********************************************************************************************
@@ -250,10 +775,11 @@ func GetFilesInfo(path string) []os.FileInfo {
 *              in front based on what type of file it is                                     *
 *                                                                                            *
 * Parameters: fileinfo : fs.FileInfo - The file to return a colored name for                 *
+*             name : string          - The name to print, as returned by FormatFilename      *
 *                                                                                            *
 * return: string                                                                             *
 **********************************************************************************************/
-func GetColorFilename(fileinfo fs.FileInfo) string {
+func GetColorFilename(fileinfo fs.FileInfo, name string) string {
 	var color string
 	if fileinfo.IsDir() {
 		color = BOLD + BLUE
@@ -265,7 +791,7 @@ func GetColorFilename(fileinfo fs.FileInfo) string {
 		}
 	}
 
-	return color + fileinfo.Name() + RESET
+	return color + name + RESET
 }
 
 /*********************************************************************************************
@@ -282,7 +808,7 @@ func GetColorFilename(fileinfo fs.FileInfo) string {
 func FilterHidden(filesInfo []fs.FileInfo) []fs.FileInfo {
 	noHidden := make([]fs.FileInfo, 0, 0)
 	for _, file := range filesInfo {
-		if file.Name()[0] != '.' {
+		if !strings.HasPrefix(file.Name(), ".") {
 			noHidden = append(noHidden, file)
 		}
 	}
@@ -290,6 +816,61 @@ func FilterHidden(filesInfo []fs.FileInfo) []fs.FileInfo {
 	return noHidden
 }
 
+/*********************************************************************************************
+*                                                                                            *
+* Name: FilterIgnored                                                                        *
+*                                                                                            *
+* Description: Removes the files matched by -I, -B and --hide from the passed in slice and   *
+*              returns a new slice without those files in it. --hide patterns are not applied*
+*              when -a or -A is given                                                        *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags        - The command line arguments for the program        *
+*              filesInfo : []fs.FileInfo - The files to filter                               *
+*                                                                                            *
+* return: []fs.FileInfo - The filtered slice                                                 *
+**********************************************************************************************/
+func FilterIgnored(ArgsFlags *Flags, filesInfo []fs.FileInfo) []fs.FileInfo {
+	applyHide := !*ArgsFlags.ShowHidden && !*ArgsFlags.AlmostAll
+
+	kept := make([]fs.FileInfo, 0, len(filesInfo))
+	for _, file := range filesInfo {
+		name := file.Name()
+		if *ArgsFlags.IgnoreBackups && strings.HasSuffix(name, "~") {
+			continue
+		}
+		if MatchesAny(*ArgsFlags.Ignore, name) {
+			continue
+		}
+		if applyHide && MatchesAny(*ArgsFlags.Hide, name) {
+			continue
+		}
+		kept = append(kept, file)
+	}
+
+	return kept
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: MatchesAny                                                                           *
+*                                                                                            *
+* Description: Reports whether a filename matches any of the given shell patterns            *
+*                                                                                            *
+* Parameters:  patterns : []string - Patterns already validated by PatternList.Set           *
+*              name : string       - The filename to test                                    *
+*                                                                                            *
+* return: bool                                                                               *
+**********************************************************************************************/
+func MatchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
 /*********************************************************************************************
 *                                                                                            *
 * Name: SortName                                                                             *
@@ -364,6 +945,34 @@ func SortTime(ArgsFlags *Flags, filesInfo []fs.FileInfo) {
 	})
 }
 
+/*********************************************************************************************
+*                                                                                            *
+* Name: SortExtension                                                                        *
+*                                                                                            *
+* Description: Sorts the given slice of filenames alphabetically by their extension, then by *
+*              name. Entries without an extension come first                                 *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags        - Command line arguments for this instance          *
+*              filesInfo : []fs.FileInfo - The files to sort                                 *
+*                                                                                            *
+* return: none                                                                               *
+**********************************************************************************************/
+func SortExtension(ArgsFlags *Flags, filesInfo []fs.FileInfo) {
+	sort.Slice(filesInfo, func(idxa, idxb int) bool {
+		keya := GetExtension(filesInfo[idxa].Name())
+		keyb := GetExtension(filesInfo[idxb].Name())
+		if keya == keyb {
+			keya, keyb = filesInfo[idxa].Name(), filesInfo[idxb].Name()
+		}
+
+		if *ArgsFlags.Reverse {
+			return keya > keyb
+		} else {
+			return keya < keyb
+		}
+	})
+}
+
 /*********************************************************************************************
 *                                                                                            *
 * Name: GetINode                                                                             *
@@ -459,153 +1068,868 @@ func GetFilePerms(fileInfo *fs.FileInfo) string {
 	return permissions
 }
 
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetFileTypeChar                                                                      *
+*                                                                                            *
+* Description: Returns the letter find uses for the type of a file: f regular file, d        *
+*              directory, l symbolic link, p named pipe, s socket, b block device and c      *
+*              character device                                                              *
+*                                                                                            *
+* Parameters: mode : fs.FileMode - The mode of the file                                      *
+*                                                                                            *
+* return: byte - the type letter                                                             *
+**********************************************************************************************/
+func GetFileTypeChar(mode fs.FileMode) byte {
+	switch {
+	case mode.IsDir():
+		return 'd'
+	case mode&fs.ModeSymlink != 0:
+		return 'l'
+	case mode&fs.ModeNamedPipe != 0:
+		return 'p'
+	case mode&fs.ModeSocket != 0:
+		return 's'
+	case mode&fs.ModeCharDevice != 0:
+		return 'c'
+	case mode&fs.ModeDevice != 0:
+		return 'b'
+	}
+
+	return 'f'
+}
+
 /*********************************************************************************************
 *                                                                                            *
 * Name: GetReadableSize                                                                      *
 *                                                                                            *
-* Description: Returns a string of the size of a file in the appropriate unit (K, M, G, T)   *
+* Description: Returns a string of the size of a file in the appropriate unit (K, M, G, T, P)*
 *                                                                                            *
 * Parameters:  size : uint64 - The size in bytes of a file                                   *
 *                                                                                            *
 * return: string - the human readable filesize                                               *
 **********************************************************************************************/
-// TODO Forgot to add GB size, fix this
 func GetReadableSize(size int64) string {
 	if size < 1024 { // Size is measureable in bytes
 		return fmt.Sprint(size)
-	} else if size >= 1024 && size < 1048576 { // Size is measureable in kilobytes
-		return fmt.Sprintf("%.1f", float64(size)/1024.0) + fmt.Sprint("K")
-	} else if size >= 1048576 && size < 1073741824 { // Size is measureable in megabytes
-		return fmt.Sprintf("%.1f", float64(size)/1048576.0) + fmt.Sprint("M")
-	} else if size >= 1073741824 && size < 1073741824 { // Size is measureable in gigabytes
-		return fmt.Sprintf("%.1f", float64(size)/1073741824.0) + fmt.Sprint("G")
-	} else { // Size is measureable in terabytes
-		return fmt.Sprintf("%.1f", float64(size)/1073741824.0) + fmt.Sprint("T")
 	}
+
+	// Move up a unit for every factor of 1024
+	value := float64(size) / 1024.0
+	unit := 0
+	for value >= 1024.0 && unit < len(sizeUnits)-1 {
+		value = value / 1024.0
+		unit++
+	}
+
+	return fmt.Sprintf("%.1f", value) + sizeUnits[unit]
 }
 
 /*********************************************************************************************
 *                                                                                            *
-* Name: SortFilterOnFlags                                                                    *
+* Name: ParseReadableSize                                                                    *
 *                                                                                            *
-* Description: sorts and filters the passed in slice of files based on the command line args *
+* Description: The reverse of GetReadableSize, turns a size such as 512, 10K or 1.5G into    *
+*              bytes. Units are case insensitive and may be followed by B                    *
 *                                                                                            *
-* Parameters:  ArgsFlags : *Flags - The command line areguments for the program              *
-*              filesInfo : *[]fs.FileInfo - The slice of files to filter                     *
+* Parameters: text : string - The size to parse                                              *
 *                                                                                            *
-* return: []fs.FileInfo - the filtered slice. The passed back slice is only different if     *
-*                         hidden files have been filtered out                                *
+* return: int64 - the size in bytes                                                          *
+*         error - non-nil if the text is not a size                                          *
 **********************************************************************************************/
-func SortFilterOnFlags(ArgsFlags *Flags, filesInfo *[]fs.FileInfo) []fs.FileInfo {
-	// Determine how to sort the entries based on the arguments
-	if *ArgsFlags.Reverse && !*ArgsFlags.SortSize && !*ArgsFlags.SortTime {
-		SortName(ArgsFlags, *filesInfo)
-	} else if *ArgsFlags.SortSize && !*ArgsFlags.SortTime {
-		SortSize(ArgsFlags, *filesInfo)
-	} else if !*ArgsFlags.SortSize && *ArgsFlags.SortTime {
-		SortTime(ArgsFlags, *filesInfo)
-	} else {
-		SortName(ArgsFlags, *filesInfo)
+func ParseReadableSize(text string) (int64, error) {
+	number := strings.TrimRight(strings.ToUpper(text), "B")
+	multiplier := 1.0
+	for idx, unit := range sizeUnits {
+		if strings.HasSuffix(number, unit) {
+			number = strings.TrimSuffix(number, unit)
+			multiplier = math.Pow(1024.0, float64(idx+1))
+			break
+		}
 	}
 
-	// If -a is not present in args, take out all hidden files from output
-	if !*ArgsFlags.ShowHidden {
-		noHidden := FilterHidden(*filesInfo)
-		filesInfo = &noHidden
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q, expected a size like 512, 10K or 1.5G", text)
 	}
 
-	return *filesInfo
+	return int64(value * multiplier), nil
 }
 
 /*********************************************************************************************
 *                                                                                            *
-* Name: PrintNormalListing                                                                   *
+* Name: ParseAge                                                                             *
 *                                                                                            *
-* Description: Prints a list of dirs and file names to the terminal                          *
+* Description: Turns a duration such as 30s, 15m, 2h, 7d, 2w or 1y into a time.Duration      *
 *                                                                                            *
-* Parameters:  ArgsFlags : *Flags - The command line areguments for the program              *
-*              filesInfo : []fs.FileInfo - The slice of files to print                       *
-*              callingDir: string        - The directory the program was called from. Used to*
-*                                          provide the correct path when recusively printing *
-*              isRecursiveCall : bool    - Tells the funciton if a path should printed before*
-*                                          normal output                                     *
+* Parameters: text : string - The duration to parse                                          *
+*                                                                                            *
+* return: time.Duration - the parsed duration                                                *
+*         error         - non-nil if the text is not a duration                              *
+**********************************************************************************************/
+func ParseAge(text string) (time.Duration, error) {
+	if text == "" {
+		return 0, fmt.Errorf("invalid duration %q, expected a duration like 2h or 30d", text)
+	}
+
+	unit, ok := ageUnits[text[len(text)-1]]
+	value, err := strconv.ParseFloat(text[:len(text)-1], 64)
+	if !ok || err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid duration %q, expected a duration like 2h or 30d", text)
+	}
+
+	return time.Duration(value * float64(unit)), nil
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: ParseTimeLimit                                                                       *
+*                                                                                            *
+* Description: Turns the value of --newer-than or --older-than into a point in time. A       *
+*              duration such as 2h or 30d is counted back from now, anything else is taken as*
+*              a reference file whose modification time is used                              *
+*                                                                                            *
+* Parameters: text : string - The duration or path to parse                                  *
+*                                                                                            *
+* return: time.Time - the point in time                                                      *
+*         error     - non-nil if the text is neither a duration nor an existing file         *
+**********************************************************************************************/
+func ParseTimeLimit(text string) (time.Time, error) {
+	age, err := ParseAge(text)
+	if err == nil {
+		return time.Now().Add(-age), nil
+	}
+
+	// Not a duration, compare against the modification time of a reference file instead
+	reference, statErr := os.Stat(text)
+	if statErr != nil {
+		return time.Time{}, fmt.Errorf("invalid age %q, expected a duration like 2h or 30d or an existing file", text)
+	}
+
+	return reference.ModTime(), nil
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetSortMode                                                                          *
+*                                                                                            *
+* Description: Decides which key the entries are sorted on. The -S, -t and -X flags take     *
+*              priority over --sort. Giving both -S and -t falls back to sorting by name     *
+*                                                                                            *
+* Parameters: ArgsFlags : *Flags - The command line arguments for the program                *
+*                                                                                            *
+* return: string - one of name, size, time or extension                                      *
+**********************************************************************************************/
+func GetSortMode(ArgsFlags *Flags) string {
+	if *ArgsFlags.SortSize && *ArgsFlags.SortTime {
+		return "name"
+	} else if *ArgsFlags.SortSize {
+		return "size"
+	} else if *ArgsFlags.SortTime {
+		return "time"
+	} else if *ArgsFlags.SortExtension {
+		return "extension"
+	}
+
+	return *ArgsFlags.SortBy
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: SortFilterOnFlags                                                                    *
+*                                                                                            *
+* Description: sorts and filters the passed in slice of files based on the command line args *
+*              The files are expected to have gone through FilterOnFlags, and AddDirSizes    *
+*              with --dir-size, already                                                      *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags - The command line areguments for the program              *
+*              filesInfo : *[]fs.FileInfo - The slice of files to filter                     *
+*              callingDir: string         - The directory the files are in                   *
+*                                                                                            *
+* return: []fs.FileInfo - the filtered slice. The passed back slice is only different if     *
+*                         files have been filtered out                                       *
+**********************************************************************************************/
+func SortFilterOnFlags(ArgsFlags *Flags, filesInfo *[]fs.FileInfo, callingDir string) []fs.FileInfo {
+	// Take out the files outside of --type, the size range and the age range
+	filtered := FilterAttributes(ArgsFlags, *filesInfo)
+
+	// Take out the files that do not match --where, before spending time sorting them
+	if ArgsFlags.WhereExpr != nil {
+		filtered = FilterWhere(ArgsFlags.WhereExpr, filtered, callingDir)
+	}
+
+	SortOnFlags(ArgsFlags, filtered)
+	return filtered
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: FilterOnFlags                                                                        *
+*                                                                                            *
+* Description: Takes out the files hidden by name: dotfiles without -a or -A, -I, -B and     *
+*              --hide patterns and files ignored by Git with --gitignore. Directories taken  *
+*              out here are not descended into by -R                                         *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags        - The command line arguments for the program        *
+*              filesInfo : []fs.FileInfo - The files to filter                               *
+*              callingDir : string       - The directory the files are in                    *
+*                                                                                            *
+* return: []fs.FileInfo - The filtered slice                                                 *
+**********************************************************************************************/
+func FilterOnFlags(ArgsFlags *Flags, filesInfo []fs.FileInfo, callingDir string) []fs.FileInfo {
+	// Paths named in --files-from are listed whatever their names, as ls does with its arguments
+	if callingDir == FILES_FROM_DIR {
+		return filesInfo
+	}
+
+	// If -a or -A is not present in args, take out all hidden files from output
+	if !*ArgsFlags.ShowHidden && !*ArgsFlags.AlmostAll {
+		filesInfo = FilterHidden(filesInfo)
+	}
+
+	// Take out the files matching -I, -B and --hide
+	filesInfo = FilterIgnored(ArgsFlags, filesInfo)
+
+	// Take out the files ignored by Git
+	if *ArgsFlags.GitIgnore {
+		filesInfo = FilterGitIgnored(filesInfo, callingDir)
+	}
+
+	return filesInfo
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: FilterAttributes                                                                     *
+*                                                                                            *
+* Description: Takes out the files whose type is not listed in --type, whose size is outside *
+*              of --min-size and --max-size or whose modification time is outside of         *
+*              --newer-than and --older-than. Like --where these only hide entries and -R    *
+*              still descends into hidden directories                                        *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags        - The command line arguments for the program        *
+*              filesInfo : []fs.FileInfo - The files to filter                               *
+*                                                                                            *
+* return: []fs.FileInfo - The filtered slice                                                 *
+**********************************************************************************************/
+func FilterAttributes(ArgsFlags *Flags, filesInfo []fs.FileInfo) []fs.FileInfo {
+	kept := make([]fs.FileInfo, 0, len(filesInfo))
+	for _, file := range filesInfo {
+		if *ArgsFlags.Types != "" && !strings.ContainsRune(*ArgsFlags.Types, rune(GetFileTypeChar(file.Mode()))) {
+			continue
+		}
+		if file.Size() < ArgsFlags.MinBytes || (ArgsFlags.MaxBytes >= 0 && file.Size() > ArgsFlags.MaxBytes) {
+			continue
+		}
+		if !ArgsFlags.NewerTime.IsZero() && !file.ModTime().After(ArgsFlags.NewerTime) {
+			continue
+		}
+		if !ArgsFlags.OlderTime.IsZero() && !file.ModTime().Before(ArgsFlags.OlderTime) {
+			continue
+		}
+
+		kept = append(kept, file)
+	}
+
+	return kept
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: SortOnFlags                                                                          *
+*                                                                                            *
+* Description: Sorts the passed in slice of files in place based on the command line args    *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags        - The command line arguments for the program        *
+*              filesInfo : []fs.FileInfo - The files to sort                                 *
 *                                                                                            *
 * return: none                                                                               *
 **********************************************************************************************/
-func PrintNormalListing(ArgsFlags *Flags, filesInfo []fs.FileInfo, callingDir string, isRecursiveCall bool) {
-	// Uses the argument flags to sort and filter the output
-	filesInfo = SortFilterOnFlags(ArgsFlags, &filesInfo)
+func SortOnFlags(ArgsFlags *Flags, filesInfo []fs.FileInfo) {
+	// Determine how to sort the entries based on the arguments
+	switch GetSortMode(ArgsFlags) {
+	case "size":
+		SortSize(ArgsFlags, filesInfo)
+	case "time":
+		SortTime(ArgsFlags, filesInfo)
+	case "extension":
+		SortExtension(ArgsFlags, filesInfo)
+	default:
+		SortName(ArgsFlags, filesInfo)
+	}
+
+	if *ArgsFlags.GroupDirsFirst {
+		SortDirsFirst(filesInfo)
+	}
+}
 
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetSubDirs                                                                           *
+*                                                                                            *
+* Description: Returns the directories -R descends into, in the order they are listed.       *
+*              Filters on the files' stat data such as --where only hide entries and do not  *
+*              stop the walk, so only the filters on names are applied                       *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags        - The command line arguments for the program        *
+*              filesInfo : []fs.FileInfo - The files of the directory left by FilterOnFlags  *
+*              callingDir : string       - The directory the files are in                    *
+*              depth : int               - How many levels callingDir is below the listed    *
+*                                          directory                                         *
+*                                                                                            *
+* return: []fs.FileInfo - the subdirectories                                                 *
+**********************************************************************************************/
+func GetSubDirs(ArgsFlags *Flags, filesInfo []fs.FileInfo, callingDir string, depth int) []fs.FileInfo {
 	dirs := make([]fs.FileInfo, 0, 0)
-	if isRecursiveCall {
-		fmt.Printf("%s:\n", callingDir)
+	if !*ArgsFlags.Recursive {
+		return dirs
+	}
+
+	// The entries of a subdirectory would be depth+2 levels below the listed directory
+	if *ArgsFlags.MaxDepth > 0 && depth+2 > *ArgsFlags.MaxDepth {
+		return dirs
 	}
 
 	for _, info := range filesInfo {
-		var finalOut string
+		if info.IsDir() && !IsDotEntry(info.Name()) && ShouldDescend(ArgsFlags, info) {
+			dirs = append(dirs, info)
+		}
+	}
+
+	SortOnFlags(ArgsFlags, dirs)
+	return dirs
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: ShouldDescend                                                                        *
+*                                                                                            *
+* Description: Tells whether -R should descend into a subdirectory: directories matching     *
+*              --exclude-dir are pruned, and with -x so are directories on another device    *
+*              than the listed directory                                                     *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags - The command line arguments for the program               *
+*              dir : fs.FileInfo  - The subdirectory                                         *
+*                                                                                            *
+* return: bool                                                                               *
+**********************************************************************************************/
+func ShouldDescend(ArgsFlags *Flags, dir fs.FileInfo) bool {
+	if MatchesAny(*ArgsFlags.ExcludeDirs, dir.Name()) {
+		return false
+	}
+
+	if *ArgsFlags.OneFileSystem && GetStatField(dir, "dev") != ArgsFlags.StartDevice {
+		return false
+	}
+
+	return true
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetOwnerName                                                                         *
+*                                                                                            *
+* Description: Returns the username of the owner of a file, or the numeric uid if it has no  *
+*              user entry                                                                    *
+*                                                                                            *
+* Parameters: fileInfo : fs.FileInfo - The file to obtain an owner for                       *
+*                                                                                            *
+* return: string - the owner                                                                 *
+**********************************************************************************************/
+func GetOwnerName(fileInfo fs.FileInfo) string {
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "?"
+	}
+
+	ownerUsr, err := user.LookupId(fmt.Sprint(stat.Uid))
+	if err != nil {
+		return fmt.Sprint(stat.Uid)
+	}
+
+	return ownerUsr.Username
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetGroupName                                                                         *
+*                                                                                            *
+* Description: Returns the name of the group of a file, or the numeric gid if it has no group*
+*              entry                                                                         *
+*                                                                                            *
+* Parameters: fileInfo : fs.FileInfo - The file to obtain a group for                        *
+*                                                                                            *
+* return: string - the group                                                                 *
+**********************************************************************************************/
+func GetGroupName(fileInfo fs.FileInfo) string {
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "?"
+	}
+
+	groupUsr, err := user.LookupGroupId(fmt.Sprint(stat.Gid))
+	if err != nil {
+		return fmt.Sprint(stat.Gid)
+	}
+
+	return groupUsr.Name
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetExtension                                                                         *
+*                                                                                            *
+* Description: Returns the text after the last dot of a filename. Names without a dot and    *
+*              dotfiles such as .bashrc have no extension                                    *
+*                                                                                            *
+* Parameters: name : string - The filename                                                   *
+*                                                                                            *
+* return: string - the extension without the dot, empty if there is none                     *
+**********************************************************************************************/
+func GetExtension(name string) string {
+	dotIdx := strings.LastIndex(name, ".")
+	if dotIdx <= 0 {
+		return ""
+	}
+
+	return name[dotIdx+1:]
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: SortDirsFirst                                                                        *
+*                                                                                            *
+* Description: Moves directories in front of all other entries while keeping the order the   *
+*              slice was already sorted in                                                   *
+*                                                                                            *
+* Parameters: filesInfo : []fs.FileInfo - The sorted files                                   *
+*                                                                                            *
+* return: none                                                                               *
+**********************************************************************************************/
+func SortDirsFirst(filesInfo []fs.FileInfo) {
+	sort.SliceStable(filesInfo, func(idxa, idxb int) bool {
+		return filesInfo[idxa].IsDir() && !filesInfo[idxb].IsDir()
+	})
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: PrintNormalListing                                                                   *
+*                                                                                            *
+* Description: Prints a list of dirs and file names to the terminal                          *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags - The command line areguments for the program              *
+*              filesInfo : []fs.FileInfo - The slice of files to print                       *
+*              callingDir: string        - The directory the program was called from. Used to*
+*                                          provide the correct path when recusively printing *
+*              depth : int               - How many levels below the listed directory        *
+*                                          callingDir is, 0 for the listed directory itself  *
+*                                                                                            *
+* return: none                                                                               *
+**********************************************************************************************/
+func PrintNormalListing(ArgsFlags *Flags, filesInfo []fs.FileInfo, callingDir string, depth int) {
+	filesInfo, dirs, listed := StartListing(ArgsFlags, filesInfo, callingDir, depth, PrintNormalListing)
+	if !listed {
+		return
+	}
+	PrintSectionHeader(ArgsFlags, callingDir, depth)
+
+	for groupIdx, group := range GroupFiles(ArgsFlags, filesInfo) {
+		PrintGroupLabel(group, groupIdx)
+
+		for _, info := range group.Files {
+			var finalOut string
+
+			if *ArgsFlags.ShowINodes {
+				inode, ok := GetINode(&info)
+				if ok != nil {
+					fmt.Printf("%s", ok)
+				}
 
-		if *ArgsFlags.ShowINodes {
-			inode, ok := GetINode(&info)
-			if ok != nil {
-				fmt.Printf("%s", ok)
+				finalOut = finalOut + fmt.Sprint(inode) + " "
 			}
 
-			finalOut = finalOut + fmt.Sprint(inode) + " "
+			name := FormatIcon(ArgsFlags, info, FormatFilename(ArgsFlags, info.Name()))
+			if !*ArgsFlags.NoColors {
+				name = GetColorFilename(info, name)
+			}
+			finalOut = finalOut + FormatHyperlink(ArgsFlags, JoinPath(callingDir, info.Name()), name)
+			finalOut = finalOut + GetIndicator(ArgsFlags, info)
+
+			// With --zero every name gets its own terminator
+			if *ArgsFlags.Zero {
+				fmt.Print(finalOut + lineEnd)
+			} else {
+				fmt.Printf("%s ", finalOut)
+			}
 		}
 
-		if *ArgsFlags.NoColors {
-			finalOut = finalOut + info.Name()
-		} else {
-			finalOut = finalOut + GetColorFilename(info)
+		if len(group.Files) > 0 && !*ArgsFlags.Zero {
+			fmt.Println()
 		}
+	}
 
-		if info.IsDir() {
-			dirs = append(dirs, info)
+	ListSubDirs(ArgsFlags, dirs, callingDir, depth, PrintNormalListing)
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: StartListing                                                                         *
+*                                                                                            *
+* Description: Does the work every listing format starts with: takes out the files hidden by *
+*              name and measures directories with --dir-size, picks the subdirectories -R    *
+*              descends into, then sorts and filters the entries and adds them to the        *
+*              --summary. Directories above --min-depth are not listed, their subdirectories *
+*              are listed right away instead                                                 *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags        - The command line arguments for the program        *
+*              filesInfo : []fs.FileInfo - All files of the directory                        *
+*              callingDir : string       - The directory the files are in                    *
+*              depth : int               - The depth of callingDir                           *
+*              printer : ListingPrinter  - The listing function of the output format         *
+*                                                                                            *
+* return: []fs.FileInfo - the entries to list, sorted                                        *
+*         []fs.FileInfo - the subdirectories to list after them                              *
+*         bool          - false if the directory is not listed                               *
+**********************************************************************************************/
+func StartListing(ArgsFlags *Flags, filesInfo []fs.FileInfo, callingDir string, depth int, printer ListingPrinter) ([]fs.FileInfo, []fs.FileInfo, bool) {
+	allFiles := filesInfo
+
+	// Files hidden by name are taken out first, so --dir-size only measures the directories that
+	// are listed or descended into, and -R walks them in the order they are printed in
+	filesInfo = FilterOnFlags(ArgsFlags, filesInfo, callingDir)
+	if *ArgsFlags.DirSize {
+		filesInfo = AddDirSizes(ArgsFlags, filesInfo, callingDir)
+	}
+	dirs := GetSubDirs(ArgsFlags, filesInfo, callingDir, depth)
+
+	// Directories above --min-depth are walked through without being listed
+	if depth+1 < *ArgsFlags.MinDepth {
+		ListSubDirs(ArgsFlags, dirs, callingDir, depth, printer)
+		return nil, nil, false
+	}
+
+	// Uses the argument flags to sort and filter the output
+	filesInfo = SortFilterOnFlags(ArgsFlags, &filesInfo, callingDir)
+	if *ArgsFlags.Summary {
+		listingSummary.Add(allFiles, filesInfo, dirs, callingDir)
+	}
+
+	return filesInfo, dirs, true
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: ListSubDirs                                                                          *
+*                                                                                            *
+* Description: Lists each subdirectory returned by GetSubDirs with the same printer as its   *
+*              parent, one level deeper                                                      *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags       - The command line arguments for the program         *
+*              dirs : []fs.FileInfo     - The subdirectories to list                         *
+*              callingDir : string      - The directory they are in                          *
+*              depth : int              - The depth of callingDir                            *
+*              printer : ListingPrinter - PrintNormalListing or PrintLongListing             *
+*                                                                                            *
+* return: none                                                                               *
+**********************************************************************************************/
+func ListSubDirs(ArgsFlags *Flags, dirs []fs.FileInfo, callingDir string, depth int, printer ListingPrinter) {
+	for _, dir := range dirs {
+		newDir := JoinPath(callingDir, dir.Name())
+		if !MarkDirListed(dir) {
+			fmt.Fprintf(os.Stderr, "vls: %s: not listing already-listed directory\n", FormatFilename(ArgsFlags, newDir))
+			exitStatus = 2
+			continue
 		}
 
-		fmt.Printf("%s ", finalOut)
+		// A directory removed or made unreadable during the walk is reported and skipped
+		recursiveFiles, err := GetFilesInfo(ArgsFlags, newDir)
+		if err != nil {
+			// Worded as GNU ls does: cannot open directory '/x': Permission denied
+			var pathErr *fs.PathError
+			if errors.As(err, &pathErr) {
+				err = pathErr.Err
+			}
+			reason := err.Error()
+			reason = strings.ToUpper(reason[:1]) + reason[1:]
+			fmt.Fprintf(os.Stderr, "vls: cannot open directory %s: %s\n", ShellQuoteName(newDir, "shell-escape-always", false, IsUTF8Locale()), reason)
+			exitStatus = max(exitStatus, 1)
+			continue
+		}
+		printer(ArgsFlags, recursiveFiles, newDir, depth+1)
 	}
+}
 
-	if len(filesInfo) > 0 {
-		fmt.Println()
+/*********************************************************************************************
+*                                                                                            *
+* Name: MarkDirListed                                                                        *
+*                                                                                            *
+* Description: Records that a directory is being listed, keyed on its device and inode so    *
+*              that a directory reached again through a symbolic link or bind mount is       *
+*              recognised                                                                    *
+*                                                                                            *
+* Parameters: dir : fs.FileInfo - The directory                                              *
+*                                                                                            *
+* return: bool - false if the directory was already listed                                   *
+**********************************************************************************************/
+func MarkDirListed(dir fs.FileInfo) bool {
+	if _, ok := dir.Sys().(*syscall.Stat_t); !ok { // Nothing to identify the directory by
+		return true
 	}
 
-	if *ArgsFlags.Recursive && len(dirs) > 0 {
-		for _, dir := range dirs {
-			fmt.Println()
-			newDir := callingDir + "/" + dir.Name()
-			recursiveFiles := GetFilesInfo(newDir)
-			PrintNormalListing(ArgsFlags, recursiveFiles, newDir, true)
+	id := DirID{Dev: GetStatField(dir, "dev"), Ino: GetStatField(dir, "inode")}
+	if listedDirs[id] {
+		return false
+	}
+
+	listedDirs[id] = true
+	return true
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: PrintSectionHeader                                                                   *
+*                                                                                            *
+* Description: Starts the listing of a directory. Listings are separated by a blank line and *
+*              the subdirectories listed by -R are introduced by their path                  *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags  - The command line arguments for the program             *
+*              callingDir : string - The directory about to be listed                        *
+*              depth : int         - The depth of callingDir, 0 for the listed directory     *
+*                                                                                            *
+* return: none                                                                               *
+**********************************************************************************************/
+func PrintSectionHeader(ArgsFlags *Flags, callingDir string, depth int) {
+	if sectionsListed > 0 {
+		fmt.Fprint(output, lineEnd)
+	}
+	if depth > 0 {
+		name := FormatHyperlink(ArgsFlags, callingDir, FormatFilename(ArgsFlags, callingDir))
+		if *ArgsFlags.Dired {
+			RecordDiredName(&diredSubdirs, output.Count+int64(len(linePrefix)), name)
 		}
+		fmt.Fprintf(output, "%s%s:%s", linePrefix, name, lineEnd)
+	}
+	sectionsListed++
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: PrintGroupLabel                                                                      *
+*                                                                                            *
+* Description: Prints the label of a --group-by section, separated from the previous section *
+*              by a blank line. Unlabeled sections print nothing                             *
+*                                                                                            *
+* Parameters:  group : FileGroup - The section about to be printed                           *
+*              groupIdx : int    - The position of the section in the listing                *
+*                                                                                            *
+* return: none                                                                               *
+**********************************************************************************************/
+func PrintGroupLabel(group FileGroup, groupIdx int) {
+	if group.Label == "" {
+		return
 	}
 
+	if groupIdx > 0 {
+		fmt.Fprint(output, lineEnd)
+	}
+	fmt.Fprintf(output, "%s%s:%s", linePrefix, group.Label, lineEnd)
 }
 
-func PrintTable(table [][]string) {
-	cols := len(table[0])
-	colSizes := make([]int, cols)
+/*********************************************************************************************
+*                                                                                            *
+* Name: VisibleWidth                                                                         *
+*                                                                                            *
+* Description: Returns the number of terminal columns a string takes up, not counting the    *
+*              escape sequences used for colors and the OSC 8 sequences of --hyperlink       *
+*                                                                                            *
+* Parameters: str : string - The string to measure                                           *
+*                                                                                            *
+* return: int - the width                                                                    *
+**********************************************************************************************/
+func VisibleWidth(str string) int {
+	width := 0
+	for idx := 0; idx < len(str); idx++ {
+		// Skip escape sequences of the form ESC [ params final-byte
+		if str[idx] == '\033' && idx+1 < len(str) && str[idx+1] == '[' {
+			idx += 2
+			for idx < len(str) && (str[idx] < 0x40 || str[idx] > 0x7e) {
+				idx++
+			}
+			continue
+		}
 
-	// Calculate the column sizes
+		// Skip operating system commands such as links, ended by BEL or ESC \
+		if str[idx] == '\033' && idx+1 < len(str) && str[idx+1] == ']' {
+			idx += 2
+			for idx < len(str) && str[idx] != '\a' && !(str[idx] == '\033' && idx+1 < len(str) && str[idx+1] == '\\') {
+				idx++
+			}
+			if idx < len(str) && str[idx] == '\033' {
+				idx++
+			}
+			continue
+		}
+
+		// Only count the first byte of each UTF-8 character
+		if str[idx]&0xc0 != 0x80 {
+			width++
+		}
+	}
+
+	return width
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: PrintTable                                                                           *
+*                                                                                            *
+* Description: Prints rows of cells as columns padded to the widest cell, each line starting *
+*              with linePrefix                                                               *
+*                                                                                            *
+* Parameters:  table : [][]string  - The rows to print, a row may have fewer cells than others*
+*              alignRight : []bool - Whether each column is padded on the left instead of the*
+*                                    right                                                   *
+*                                                                                            *
+* return: [][]int64 - the offset in the output of the first byte of every cell, used by      *
+*                     --dired                                                                *
+**********************************************************************************************/
+func PrintTable(table [][]string, alignRight []bool) [][]int64 {
+	if len(table) == 0 {
+		return nil
+	}
+
+	// Calculate the column sizes, from the row with the most cells
+	cols := 0
+	for _, row := range table {
+		cols = max(cols, len(row))
+	}
+	colSizes := make([]int, cols)
 	for _, row := range table {
 		for coli, col := range row {
-			length := len(col)
+			length := VisibleWidth(col)
 			if length > colSizes[coli] {
 				colSizes[coli] = length
 			}
 		}
 	}
 
-	// Print out the table
+	// Print out the table. Only the end of the rows is trimmed, the padding of a right aligned
+	// first column keeps the rows lined up
+	cellOffsets := make([][]int64, 0, len(table))
 	for _, row := range table {
-		var outRow string
+		outRow := linePrefix
+		offsets := make([]int64, 0, len(row))
 
 		for coli, col := range row {
-			outRow = outRow + fmt.Sprintf("%-*s ", colSizes[coli], col)
+			padding := strings.Repeat(" ", colSizes[coli]-VisibleWidth(col))
+			if coli < len(alignRight) && alignRight[coli] {
+				outRow = outRow + padding
+				offsets = append(offsets, output.Count+int64(len(outRow)))
+				outRow = outRow + col + " "
+			} else {
+				offsets = append(offsets, output.Count+int64(len(outRow)))
+				outRow = outRow + col + padding + " "
+			}
 		}
 
-		outRow = strings.TrimSpace(outRow)
-		fmt.Print(outRow + "\n")
+		outRow = strings.TrimRight(outRow, " ")
+		fmt.Fprint(output, outRow+lineEnd)
+		cellOffsets = append(cellOffsets, offsets)
+	}
+
+	return cellOffsets
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetGroupKey                                                                          *
+*                                                                                            *
+* Description: Returns the section label an entry belongs to for the active --group-by mode, *
+*              along with a key that orders the sections                                     *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags - The command line arguments for the program               *
+*              info : fs.FileInfo - The file to find a section for                           *
+*                                                                                            *
+* return: string - the key used to order sections                                            *
+*         string - the label printed above the section                                       *
+**********************************************************************************************/
+func GetGroupKey(ArgsFlags *Flags, info fs.FileInfo) (string, string) {
+	switch *ArgsFlags.GroupBy {
+	case "type":
+		var label string
+		mode := info.Mode()
+		if mode.IsDir() {
+			label = "Directories"
+		} else if mode&fs.ModeSymlink != 0 {
+			label = "Symbolic links"
+		} else if !mode.IsRegular() {
+			label = "Special files"
+		} else if mode&os.ModePerm&0100 != 0 {
+			label = "Executables"
+		} else {
+			label = "Files"
+		}
+
+		for idx, typeLabel := range typeGroupLabels {
+			if typeLabel == label {
+				return fmt.Sprint(idx), label
+			}
+		}
+		return label, label
+	case "extension":
+		if info.IsDir() {
+			return "0", "Directories"
+		}
+
+		ext := GetExtension(info.Name())
+		if ext == "" {
+			return "1", "No extension"
+		}
+		return "2" + ext, "." + ext + " files"
+	case "owner":
+		owner := GetOwnerName(info)
+		return owner, owner
+	}
+
+	return "", ""
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GroupFiles                                                                           *
+*                                                                                            *
+* Description: Splits the sorted files into labeled sections based on --group-by. Every      *
+*              section keeps the order of the passed in slice. Without --group-by a single   *
+*              unlabeled section is returned                                                 *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags        - The command line arguments for the program        *
+*              filesInfo : []fs.FileInfo - The sorted files to group                         *
+*                                                                                            *
+* return: []FileGroup - the sections in the order they should be printed                     *
+**********************************************************************************************/
+func GroupFiles(ArgsFlags *Flags, filesInfo []fs.FileInfo) []FileGroup {
+	if *ArgsFlags.GroupBy == "" {
+		return []FileGroup{{Files: filesInfo}}
+	}
+
+	groups := make([]FileGroup, 0)
+	keyIdx := make(map[string]int)
+	for _, info := range filesInfo {
+		key, label := GetGroupKey(ArgsFlags, info)
+		idx, ok := keyIdx[key]
+		if !ok {
+			idx = len(groups)
+			keyIdx[key] = idx
+			groups = append(groups, FileGroup{Label: label, key: key})
+		}
+		groups[idx].Files = append(groups[idx].Files, info)
 	}
+
+	sort.Slice(groups, func(idxa, idxb int) bool {
+		return groups[idxa].key < groups[idxb].key
+	})
+	return groups
 }
 
 /*********************************************************************************************
@@ -619,109 +1943,77 @@ func PrintTable(table [][]string) {
 *              filesInfo : []fs.FileInfo - The slice of files to print                       *
 *              callingDir: string        - The directory the program was called from. Used to*
 *                                          provide the correct path when recusively printing *
-*              isRecursiveCall : bool    - Tells the funciton if a path should printed before*
-*                                          normal output                                     *
+*              depth : int               - How many levels below the listed directory        *
+*                                          callingDir is, 0 for the listed directory itself  *
 *                                                                                            *
 * return: none                                                                               *
 **********************************************************************************************/
-func PrintLongListing(ArgsFlags *Flags, filesInfo []fs.FileInfo, callingDir string, isRecursiveCall bool) {
-	filesInfo = SortFilterOnFlags(ArgsFlags, &filesInfo)
-
-	// Allocate the memory that will store the info for each file
-	outTable := make([][]string, len(filesInfo))
-	for idx := 0; idx < len(filesInfo); idx++ {
-		outTable[idx] = make([]string, 0)
+func PrintLongListing(ArgsFlags *Flags, filesInfo []fs.FileInfo, callingDir string, depth int) {
+	filesInfo, dirs, listed := StartListing(ArgsFlags, filesInfo, callingDir, depth, PrintLongListing)
+	if !listed {
+		return
 	}
 
-	dirs := make([]fs.FileInfo, 0, 0)
-	if isRecursiveCall {
-		fmt.Printf("%s:\n", callingDir)
-	}
+	PrintSectionHeader(ArgsFlags, callingDir, depth)
 
-	var totalSize int64
-	for idx, info := range filesInfo {
+	groups := GroupFiles(ArgsFlags, filesInfo)
 
-		stat, syscallOk := info.Sys().(*syscall.Stat_t)
-		if !syscallOk {
-			fmt.Printf("syscall Stat_t failed: %v\n", syscallOk)
-		}
+	// Fill in one row per file with the value of every column
+	outTable := make([][]string, 0, len(filesInfo))
+	var totalSize int64
+	for _, group := range groups {
+		for _, info := range group.Files {
+			entry := GetColumnEntry(ArgsFlags.LongColumns, info, callingDir)
 
-		// File inode
-		var inode string
-		if *ArgsFlags.ShowINodes {
-			inodeInt, ok := GetINode(&info)
-			if ok != nil {
-				fmt.Printf("%s", ok)
+			row := make([]string, 0, len(ArgsFlags.LongColumns))
+			for _, column := range ArgsFlags.LongColumns {
+				row = append(row, column.Value(ArgsFlags, entry))
 			}
-			inode = fmt.Sprint(inodeInt)
+			outTable = append(outTable, row)
+			totalSize = totalSize + int64(info.Size())
 		}
-		outTable[idx] = append(outTable[idx], inode)
-
-		// File permissions
-		var permissions string = GetFilePerms(&info)
-		outTable[idx] = append(outTable[idx], permissions)
-
-		// Number of hard links
-		var numLinks string = fmt.Sprint(stat.Nlink)
-		outTable[idx] = append(outTable[idx], numLinks)
-
-		// Get the owner of the file
-		ownerUsr, ok := user.LookupId(fmt.Sprint(stat.Uid))
-		if ok != nil {
-			fmt.Printf("%s\n", ok)
-		}
-		var owner string = ownerUsr.Username
-		outTable[idx] = append(outTable[idx], owner)
-
-		// Group of the file
-		var group string
-		groupUsr, ok := user.LookupGroupId(fmt.Sprint(stat.Gid))
-		if ok != nil {
-			fmt.Printf("%s\n", ok)
-		}
-		group = groupUsr.Name
-		outTable[idx] = append(outTable[idx], group)
-
-		// Size of the file
-		var size string
-		if !*ArgsFlags.HumanReadable {
-			size = fmt.Sprint(info.Size())
-		} else {
-			size = GetReadableSize(info.Size())
-		}
-		totalSize = totalSize + int64(info.Size())
-		outTable[idx] = append(outTable[idx], size)
-
-		// Date/time modified
-		var dateTime string = info.ModTime().Format("Jan 02 15:04")
-		outTable[idx] = append(outTable[idx], dateTime)
-
-		// Get the filename
-		var filename string
-		if *ArgsFlags.NoColors {
-			filename = info.Name()
-		} else {
-			filename = GetColorFilename(info)
-		}
-		outTable[idx] = append(outTable[idx], filename)
+	}
 
-		if info.IsDir() {
-			dirs = append(dirs, info)
+	// The bars are scaled against all entries of the directory, across sections
+	if *ArgsFlags.Bars {
+		rowFiles := make([]fs.FileInfo, 0, len(filesInfo))
+		for _, group := range groups {
+			rowFiles = append(rowFiles, group.Files...)
 		}
+		AppendBars(ArgsFlags, outTable, rowFiles)
 	}
+
 	if *ArgsFlags.HumanReadable {
-		fmt.Printf("total %s\n", GetReadableSize(totalSize))
+		fmt.Fprintf(output, "%stotal %s%s", linePrefix, GetReadableSize(totalSize), lineEnd)
 	} else {
-		fmt.Printf("total %v\n", totalSize)
+		fmt.Fprintf(output, "%stotal %v%s", linePrefix, totalSize, lineEnd)
 	}
-	PrintTable(outTable)
 
-	if *ArgsFlags.Recursive && len(dirs) > 0 {
-		for _, dir := range dirs {
-			fmt.Println()
-			newDir := callingDir + "/" + dir.Name()
-			recursiveFiles := GetFilesInfo(newDir)
-			PrintLongListing(ArgsFlags, recursiveFiles, newDir, true)
+	alignRight := make([]bool, 0, len(ArgsFlags.LongColumns))
+	for _, column := range ArgsFlags.LongColumns {
+		alignRight = append(alignRight, column.AlignRight)
+	}
+
+	// Each section is laid out as its own table, under its own header row
+	rowStart := 0
+	for groupIdx, group := range groups {
+		PrintGroupLabel(group, groupIdx)
+		section := outTable[rowStart : rowStart+len(group.Files)]
+		if *ArgsFlags.Header && len(section) > 0 {
+			section = append([][]string{GetHeaderRow(ArgsFlags)}, section...)
+		}
+		cellOffsets := PrintTable(section, alignRight)
+		rowStart += len(group.Files)
+
+		// The name cell starts with the name, followed by the indicator of -F
+		nameCol := GetColumnIndex(ArgsFlags.LongColumns, "name")
+		if *ArgsFlags.Dired && nameCol >= 0 && len(cellOffsets) > 0 {
+			cellOffsets = cellOffsets[len(cellOffsets)-len(group.Files):]
+			for fileIdx, info := range group.Files {
+				RecordDiredName(&diredNames, cellOffsets[fileIdx][nameCol], FormatFilename(ArgsFlags, info.Name()))
+			}
 		}
 	}
+
+	ListSubDirs(ArgsFlags, dirs, callingDir, depth, PrintLongListing)
 }
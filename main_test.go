@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// Set in the environment of the test binary when it is run as vls by runVls
+const RUN_VLS_ENV = "VLS_TEST_RUN_MAIN"
+
+// The test binary runs main instead of the tests when runVls starts it, so each run gets fresh
+// flags and package state
+func TestMain(m *testing.M) {
+	if os.Getenv(RUN_VLS_ENV) == "1" {
+		main()
+	}
+
+	os.Exit(m.Run())
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: runVls                                                                               *
+*                                                                                            *
+* Description: Runs vls with the given arguments in a directory, in a separate process       *
+*                                                                                            *
+* Parameters:  t : *testing.T   - The running test                                           *
+*              dir : string     - The directory to run vls in                                *
+*              args : ...string - The vls arguments                                          *
+*                                                                                            *
+* return: string - the standard output                                                       *
+*         string - the standard error                                                        *
+*         int    - the exit status                                                           *
+**********************************************************************************************/
+func runVls(t *testing.T, dir string, args ...string) (string, string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), RUN_VLS_ENV+"=1", "COLUMNS=80", "LC_ALL=C.UTF-8")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	status := 0
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			t.Fatalf("running vls: %v", err)
+		}
+		status = exitErr.ExitCode()
+	}
+
+	return stdout.String(), stderr.String(), status
+}
+
+func TestLongListingBarsHeader(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "small", "1\n")
+	writeFile(t, dir, "large", strings.Repeat("1", 300)+"\n")
+
+	stdout, stderr, status := runVls(t, dir, "-l", "-G", "--bars", "--header")
+	if status != 0 {
+		t.Fatalf("vls exited with %d: %s", status, stderr)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want the total, the header and 2 files:\n%s", len(lines), stdout)
+	}
+	if !strings.HasSuffix(lines[1], "Share") {
+		t.Errorf("header %q does not end with the bar title", lines[1])
+	}
+
+	// The bar title lines up with the bar of the larger file, which is listed first
+	shareCol := len([]rune(strings.TrimSuffix(lines[1], "Share")))
+	for _, line := range lines[2:] {
+		if !strings.HasSuffix(line, "%") {
+			t.Errorf("row %q does not end with a percentage", line)
+		}
+	}
+	if bar := []rune(lines[2]); len(bar) <= shareCol || bar[shareCol] != '█' {
+		t.Errorf("bar of %q does not start under the title", lines[2])
+	}
+}
+
+func TestSummaryDirSizeRecursive(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "top", strings.Repeat("1", 1000))
+	writeFile(t, dir, "sub/middle", strings.Repeat("1", 3000))
+	writeFile(t, dir, "sub/deeper/bottom", strings.Repeat("1", 50))
+
+	sizeLine := func(args ...string) string {
+		stdout, stderr, status := runVls(t, dir, args...)
+		if status != 0 {
+			t.Fatalf("vls %s exited with %d: %s", strings.Join(args, " "), status, stderr)
+		}
+		for _, line := range strings.Split(stdout, "\n") {
+			if strings.HasPrefix(line, "Size: ") {
+				return line
+			}
+		}
+		t.Fatalf("vls %s printed no size:\n%s", strings.Join(args, " "), stdout)
+		return ""
+	}
+
+	// The contents of the subdirectories are counted once, whether or not --du measured them
+	if plain, measured := sizeLine("-R", "--summary"), sizeLine("--du", "-R", "--summary"); plain != measured {
+		t.Errorf("--du -R --summary printed %q, -R --summary printed %q", measured, plain)
+	}
+}
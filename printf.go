@@ -0,0 +1,344 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A piece of a --printf format: either literal text or a directive such as %-10s
+type PrintfDirective struct {
+	Literal string // Text printed as is when Verb is 0
+	Flags   string // The flags, width and precision between the % and the verb
+	Verb    byte
+	Field   byte // The time field of %T, %A and %C
+}
+
+// The directives --printf accepts, see FormatDirective
+const printfVerbs = "pfhskmMugUGinlytTaAcC"
+
+// The time fields of %T, %A and %C, as find documents them
+const printfTimeFields = "@HIklMprSTXZaAbBcDdFhjmUwWxyY+s"
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: ParsePrintf                                                                          *
+*                                                                                            *
+* Description: Splits a --printf format into literal text and directives. Backslash escapes  *
+*              such as \n, \t and \0 are turned into the characters they stand for           *
+*                                                                                            *
+* Parameters: format : string - The format                                                   *
+*                                                                                            *
+* return: []PrintfDirective - the pieces of the format in order                              *
+*         error             - non-nil if the format holds an unknown directive               *
+**********************************************************************************************/
+func ParsePrintf(format string) ([]PrintfDirective, error) {
+	directives := make([]PrintfDirective, 0)
+	var literal strings.Builder
+
+	for idx := 0; idx < len(format); idx++ {
+		switch format[idx] {
+		case '\\':
+			escaped, length := ParseEscape(format[idx+1:])
+			literal.WriteString(escaped)
+			idx += length
+
+		case '%':
+			// Flags, width and precision come before the verb
+			end := idx + 1
+			for end < len(format) && strings.IndexByte("-+ #0123456789.", format[end]) >= 0 {
+				end++
+			}
+			if end >= len(format) {
+				return nil, fmt.Errorf("missing directive at the end of %q", format)
+			}
+
+			if format[end] == '%' {
+				literal.WriteByte('%')
+				idx = end
+				continue
+			}
+
+			directive := PrintfDirective{Flags: format[idx+1 : end], Verb: format[end]}
+			if strings.IndexByte(printfVerbs, directive.Verb) < 0 {
+				return nil, fmt.Errorf("unknown directive %%%c", directive.Verb)
+			}
+			if strings.IndexByte("TAC", directive.Verb) >= 0 {
+				end++
+				if end >= len(format) || strings.IndexByte(printfTimeFields, format[end]) < 0 {
+					return nil, fmt.Errorf("%%%c needs a time field such as Y, m, d, H, M, S or @", directive.Verb)
+				}
+				directive.Field = format[end]
+			}
+
+			if literal.Len() > 0 {
+				directives = append(directives, PrintfDirective{Literal: literal.String()})
+				literal.Reset()
+			}
+			directives = append(directives, directive)
+			idx = end
+
+		default:
+			literal.WriteByte(format[idx])
+		}
+	}
+
+	if literal.Len() > 0 {
+		directives = append(directives, PrintfDirective{Literal: literal.String()})
+	}
+
+	return directives, nil
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: ParseEscape                                                                          *
+*                                                                                            *
+* Description: Reads the escape sequence following a backslash: \a \b \f \n \r \t \v \\ or up*
+*              to three octal digits. An unknown escape is kept with its backslash           *
+*                                                                                            *
+* Parameters: text : string - The format after the backslash                                 *
+*                                                                                            *
+* return: string - the character the escape stands for                                       *
+*         int    - the number of bytes of text used                                          *
+**********************************************************************************************/
+func ParseEscape(text string) (string, int) {
+	if text == "" {
+		return "\\", 0
+	}
+
+	if digits := len(text) - len(strings.TrimLeft(text, "01234567")); digits > 0 {
+		digits = min(digits, 3)
+		value, _ := strconv.ParseUint(text[:digits], 8, 8)
+		return string([]byte{byte(value)}), digits
+	}
+
+	switch text[0] {
+	case 'a':
+		return "\a", 1
+	case 'b':
+		return "\b", 1
+	case 'f':
+		return "\f", 1
+	case 'n':
+		return "\n", 1
+	case 'r':
+		return "\r", 1
+	case 't':
+		return "\t", 1
+	case 'v':
+		return "\v", 1
+	case '\\':
+		return "\\", 1
+	}
+
+	return "\\" + text[:1], 1
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: FormatDirective                                                                      *
+*                                                                                            *
+* Description: Returns the value of a --printf directive for a file, padded and cut by its   *
+*              width and precision. %p path, %f name, %h directory, %s size, %k disk space in*
+*              KiB, %m octal and %M symbolic permissions, %u %g owner and group, %U %G their *
+*              ids, %i inode, %n links, %l link target, %y type, %t %a %c modification,      *
+*              access and change times and %T %A %C one field of them                        *
+*                                                                                            *
+* Parameters:  directive : PrintfDirective - The directive                                   *
+*              info : fs.FileInfo          - The file                                        *
+*              callingDir : string         - The directory the file is in                    *
+*                                                                                            *
+* return: string - the formatted value                                                       *
+**********************************************************************************************/
+func FormatDirective(directive PrintfDirective, info fs.FileInfo, callingDir string) string {
+	if directive.Verb == 0 {
+		return directive.Literal
+	}
+
+	var value string
+	switch directive.Verb {
+	case 'p':
+		value = JoinPath(callingDir, info.Name())
+	case 'f':
+		value = info.Name()
+	case 'h':
+		value = callingDir
+	case 's':
+		value = fmt.Sprint(info.Size())
+	case 'k':
+		value = fmt.Sprint((GetStatField(info, "blocks") + 1) / 2)
+	case 'm':
+		value = fmt.Sprintf("%o", GetUnixMode(info))
+	case 'M':
+		value = GetLsTypeChar(info.Mode()) + GetFilePerms(&info)[1:]
+	case 'u':
+		value = GetOwnerName(info)
+	case 'g':
+		value = GetGroupName(info)
+	case 'U':
+		value = fmt.Sprint(GetStatField(info, "uid"))
+	case 'G':
+		value = fmt.Sprint(GetStatField(info, "gid"))
+	case 'i':
+		value = fmt.Sprint(GetStatField(info, "inode"))
+	case 'n':
+		value = fmt.Sprint(GetStatField(info, "nlink"))
+	case 'l':
+		if info.Mode()&fs.ModeSymlink != 0 {
+			value, _ = os.Readlink(JoinPath(callingDir, info.Name()))
+		}
+	case 'y':
+		value = string(GetFileTypeChar(info.Mode()))
+	case 't':
+		value = FormatFindTime(info.ModTime())
+	case 'a':
+		value = FormatFindTime(GetStatTime(info, 'a'))
+	case 'c':
+		value = FormatFindTime(GetStatTime(info, 'c'))
+	case 'T':
+		value = FormatTimeField(info.ModTime(), directive.Field)
+	case 'A':
+		value = FormatTimeField(GetStatTime(info, 'a'), directive.Field)
+	case 'C':
+		value = FormatTimeField(GetStatTime(info, 'c'), directive.Field)
+	}
+
+	if directive.Flags == "" {
+		return value
+	}
+	return fmt.Sprintf("%"+directive.Flags+"s", value)
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: FormatTimeField                                                                      *
+*                                                                                            *
+* Description: Formats one field of a time the way find's %T directive does, with the letters*
+*              of strftime: Y year, m month, d day, H hour, M minute, S second, @ seconds    *
+*              since the epoch, F date, T time and so on                                     *
+*                                                                                            *
+* Parameters:  t : time.Time - The time                                                      *
+*              field : byte  - The field letter                                              *
+*                                                                                            *
+* return: string - the formatted field                                                       *
+**********************************************************************************************/
+func FormatTimeField(t time.Time, field byte) string {
+	switch field {
+	case '@':
+		return fmt.Sprint(t.Unix()) + GetFindFraction(t)
+	case 's':
+		return fmt.Sprint(t.Unix())
+	case 'S':
+		return t.Format("05") + GetFindFraction(t)
+	case 'T':
+		return t.Format("15:04:05") + GetFindFraction(t)
+	case '+':
+		return t.Format("2006-01-02+15:04:05") + GetFindFraction(t)
+	case 'k':
+		return fmt.Sprintf("%2d", t.Hour())
+	case 'l':
+		return fmt.Sprintf("%2d", (t.Hour()+11)%12+1)
+	case 'j':
+		return fmt.Sprintf("%03d", t.YearDay())
+	case 'U':
+		return fmt.Sprintf("%02d", (t.YearDay()+6-int(t.Weekday()))/7)
+	case 'W':
+		return fmt.Sprintf("%02d", (t.YearDay()+6-(int(t.Weekday())+6)%7)/7)
+	case 'w':
+		return fmt.Sprint(int(t.Weekday()))
+	}
+
+	// The remaining fields map onto Go layouts
+	layouts := map[byte]string{
+		'H': "15", 'I': "03", 'M': "04", 'p': "PM", 'r': "03:04:05 PM", 'X': "15:04:05", 'Z': "MST",
+		'a': "Mon", 'A': "Monday", 'b': "Jan", 'h': "Jan", 'B': "January", 'c': "Mon Jan _2 15:04:05 2006",
+		'D': "01/02/06", 'x': "01/02/06", 'd': "02", 'F': "2006-01-02", 'm': "01", 'y': "06", 'Y': "2006",
+	}
+	return t.Format(layouts[field])
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: FormatFindTime                                                                       *
+*                                                                                            *
+* Description: Formats a time the way find's %t, %a and %c directives do, in the layout of   *
+*              ctime with the fraction of the second                                         *
+*                                                                                            *
+* Parameters: t : time.Time - The time                                                       *
+*                                                                                            *
+* return: string - such as Mon Oct 19 12:07:39.1342038040 2026                               *
+**********************************************************************************************/
+func FormatFindTime(t time.Time) string {
+	return t.Format("Mon Jan _2 15:04:05") + GetFindFraction(t) + t.Format(" 2006")
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetFindFraction                                                                      *
+*                                                                                            *
+* Description: Returns the fraction of the second find prints after seconds, which has ten   *
+*              digits                                                                        *
+*                                                                                            *
+* Parameters: t : time.Time - The time                                                       *
+*                                                                                            *
+* return: string - the fraction with its leading dot                                         *
+**********************************************************************************************/
+func GetFindFraction(t time.Time) string {
+	return fmt.Sprintf(".%09d0", t.Nanosecond())
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetLsTypeChar                                                                        *
+*                                                                                            *
+* Description: Returns the character ls prints before the permissions for the type of a file *
+*                                                                                            *
+* Parameters: mode : fs.FileMode - The mode of the file                                      *
+*                                                                                            *
+* return: string - - for a regular file, otherwise the find type letter                      *
+**********************************************************************************************/
+func GetLsTypeChar(mode fs.FileMode) string {
+	if typeChar := GetFileTypeChar(mode); typeChar != 'f' {
+		return string(typeChar)
+	}
+
+	return "-"
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: PrintPrintfListing                                                                   *
+*                                                                                            *
+* Description: Prints every entry of a directory with the --printf format. Like find, no     *
+*              newline is added, the format has to hold one                                  *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags        - The command line arguments for the program        *
+*              filesInfo : []fs.FileInfo - The slice of files to print                       *
+*              callingDir: string        - The directory the files are in                    *
+*              depth : int               - The depth of callingDir, 0 for the listed         *
+*                                          directory                                         *
+*                                                                                            *
+* return: none                                                                               *
+**********************************************************************************************/
+func PrintPrintfListing(ArgsFlags *Flags, filesInfo []fs.FileInfo, callingDir string, depth int) {
+	filesInfo, dirs, listed := StartListing(ArgsFlags, filesInfo, callingDir, depth, PrintPrintfListing)
+	if !listed {
+		return
+	}
+
+	var out strings.Builder
+	for _, group := range GroupFiles(ArgsFlags, filesInfo) {
+		for _, info := range group.Files {
+			for _, directive := range ArgsFlags.PrintfFormat {
+				out.WriteString(FormatDirective(directive, info, callingDir))
+			}
+		}
+	}
+	fmt.Print(out.String())
+
+	ListSubDirs(ArgsFlags, dirs, callingDir, depth, PrintPrintfListing)
+}
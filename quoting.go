@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The values --quoting-style accepts
+var quotingStyles = []string{"literal", "shell", "shell-always", "shell-escape", "shell-escape-always", "c", "escape", "locale"}
+
+// Characters that make the shell styles quote a name. # and ~ only matter at the start of a word
+// and { and } only on their own
+const shellSpecials = " \t\n!\"$&'()*;<=>?[\\^`|"
+
+// Backslash escapes of the c and escape styles, other unprintable bytes are written in octal
+var cEscapes = map[rune]string{
+	'\a': `\a`, '\b': `\b`, '\f': `\f`, '\n': `\n`, '\r': `\r`, '\t': `\t`, '\v': `\v`, '\\': `\\`,
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetQuotingStyle                                                                      *
+*                                                                                            *
+* Description: Decides how names are quoted. -Q, -b and --quoting-style win over the         *
+*              QUOTING_STYLE environment variable, which is ignored with a warning when it is*
+*              invalid. Without any of them names are shell-escape quoted on a terminal and  *
+*              printed as they are otherwise, as GNU ls does                                 *
+*                                                                                            *
+* Parameters: ArgsFlags : *Flags - The command line arguments for the program                *
+*                                                                                            *
+* return: string - one of quotingStyles                                                      *
+*         error  - non-nil if the style is unknown                                           *
+**********************************************************************************************/
+func GetQuotingStyle(ArgsFlags *Flags) (string, error) {
+	style := *ArgsFlags.QuotingStyle
+	switch {
+	case *ArgsFlags.QuoteName:
+		style = "c"
+	case *ArgsFlags.Escape:
+		style = "escape"
+	case style == "":
+		style = os.Getenv("QUOTING_STYLE")
+		if style != "" && !IsQuotingStyle(style) {
+			fmt.Fprintf(os.Stderr, "vls: ignoring invalid value of environment variable QUOTING_STYLE: %s\n", style)
+			style = ""
+		}
+	}
+
+	if style == "" {
+		if IsTerminal(os.Stdout) {
+			return "shell-escape", nil
+		}
+		return "literal", nil
+	} else if IsQuotingStyle(style) {
+		return style, nil
+	}
+	return "", fmt.Errorf("invalid quoting style %q, expected one of %s", style, strings.Join(quotingStyles, ", "))
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: IsQuotingStyle                                                                       *
+*                                                                                            *
+* Description: Tells whether a string names one of the quoting styles                        *
+*                                                                                            *
+* Parameters: style : string - The name to check                                             *
+*                                                                                            *
+* return: bool                                                                               *
+**********************************************************************************************/
+func IsQuotingStyle(style string) bool {
+	for _, known := range quotingStyles {
+		if style == known {
+			return true
+		}
+	}
+	return false
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: FormatFilename                                                                       *
+*                                                                                            *
+* Description: Returns a name the way it is printed with the quoting style of the listing.   *
+*              Every name and path written to the terminal goes through here, so a name      *
+*              holding control characters cannot move the cursor or forge other entries      *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags - The command line arguments for the program               *
+*              name : string      - The name as it is on disk                                *
+*                                                                                            *
+* return: string - the name to print                                                         *
+**********************************************************************************************/
+func FormatFilename(ArgsFlags *Flags, name string) string {
+	utf8Locale := IsUTF8Locale()
+
+	switch ArgsFlags.Quoting {
+	case "shell", "shell-always", "shell-escape", "shell-escape-always":
+		return ShellQuoteName(name, ArgsFlags.Quoting, *ArgsFlags.HideControl, utf8Locale)
+	case "c":
+		return `"` + CEscapeName(name, `"`, utf8Locale) + `"`
+	case "escape":
+		return CEscapeName(name, " ", utf8Locale)
+	case "locale":
+		if utf8Locale {
+			return "‘" + CEscapeName(name, "", utf8Locale) + "’"
+		}
+		return "'" + CEscapeName(name, "'", utf8Locale) + "'"
+	}
+
+	if *ArgsFlags.HideControl {
+		return HideUnprintable(name, utf8Locale)
+	}
+	return name
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: IsPrintableRune                                                                      *
+*                                                                                            *
+* Description: Tells whether a character decoded from a name can be printed as it is. Outside*
+*              of UTF-8 locales only printable ASCII is                                      *
+*                                                                                            *
+* Parameters:  char : rune       - The character, utf8.RuneError for an invalid byte         *
+*              size : int        - The number of bytes it was decoded from                   *
+*              utf8Locale : bool - Whether the terminal takes UTF-8                          *
+*                                                                                            *
+* return: bool                                                                               *
+**********************************************************************************************/
+func IsPrintableRune(char rune, size int, utf8Locale bool) bool {
+	if char == utf8.RuneError && size <= 1 {
+		return false
+	}
+	if char >= utf8.RuneSelf {
+		return utf8Locale && unicode.IsPrint(char)
+	}
+
+	return char >= ' ' && char != 0x7f
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: HideUnprintable                                                                      *
+*                                                                                            *
+* Description: Replaces every unprintable character or invalid byte of a name with ?, as -q  *
+*              does                                                                          *
+*                                                                                            *
+* Parameters:  name : string     - The name                                                  *
+*              utf8Locale : bool - Whether the terminal takes UTF-8                          *
+*                                                                                            *
+* return: string - the name with ? in place of unprintable characters                        *
+**********************************************************************************************/
+func HideUnprintable(name string, utf8Locale bool) string {
+	var hidden strings.Builder
+	for idx := 0; idx < len(name); {
+		char, size := utf8.DecodeRuneInString(name[idx:])
+		if IsPrintableRune(char, size, utf8Locale) {
+			hidden.WriteString(name[idx : idx+size])
+		} else if utf8Locale {
+			hidden.WriteByte('?')
+		} else {
+			hidden.WriteString(strings.Repeat("?", size))
+		}
+		idx += size
+	}
+
+	return hidden.String()
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: CEscapeName                                                                          *
+*                                                                                            *
+* Description: Escapes a name the way C string literals are written: backslash escapes such  *
+*              as \n for control characters and three digit octal escapes for other          *
+*              unprintable bytes, invalid UTF-8 included                                     *
+*                                                                                            *
+* Parameters:  name : string     - The name                                                  *
+*              special : string  - Characters that also get a backslash, such as the quote   *
+*                                  around the name                                           *
+*              utf8Locale : bool - Whether the terminal takes UTF-8                          *
+*                                                                                            *
+* return: string - the escaped name                                                          *
+**********************************************************************************************/
+func CEscapeName(name string, special string, utf8Locale bool) string {
+	var escaped strings.Builder
+	for idx := 0; idx < len(name); {
+		char, size := utf8.DecodeRuneInString(name[idx:])
+
+		if sequence, ok := cEscapes[char]; ok && size == 1 {
+			escaped.WriteString(sequence)
+		} else if !IsPrintableRune(char, size, utf8Locale) {
+			for _, b := range []byte(name[idx : idx+size]) {
+				fmt.Fprintf(&escaped, "\\%03o", b)
+			}
+		} else {
+			if size == 1 && strings.ContainsRune(special, char) {
+				escaped.WriteByte('\\')
+			}
+			escaped.WriteString(name[idx : idx+size])
+		}
+		idx += size
+	}
+
+	return escaped.String()
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: ShellQuoteName                                                                       *
+*                                                                                            *
+* Description: Quotes a name so it can be pasted into a POSIX shell. Names without special   *
+*              characters are left alone unless the style ends in -always. Unprintable       *
+*              characters are written as $'\n' sequences by the shell-escape styles and as ? *
+*              by the others when hideControl is set                                         *
+*                                                                                            *
+* Parameters:  name : string      - The name                                                 *
+*              style : string     - shell, shell-always, shell-escape or shell-escape-always *
+*              hideControl : bool - Whether -q is in effect                                  *
+*              utf8Locale : bool  - Whether the terminal takes UTF-8                         *
+*                                                                                            *
+* return: string - the quoted name                                                           *
+**********************************************************************************************/
+func ShellQuoteName(name string, style string, hideControl bool, utf8Locale bool) string {
+	escapeStyle := strings.HasPrefix(style, "shell-escape")
+	needsQuotes := strings.HasSuffix(style, "-always") || name == "" || strings.ContainsAny(name, shellSpecials) ||
+		strings.ContainsAny(name[:1], "#~") || name == "{" || name == "}"
+	if !escapeStyle && hideControl {
+		name = HideUnprintable(name, utf8Locale)
+	}
+	printable := true
+	for idx := 0; idx < len(name); {
+		char, size := utf8.DecodeRuneInString(name[idx:])
+		printable = printable && IsPrintableRune(char, size, utf8Locale)
+		idx += size
+	}
+
+	if !needsQuotes && (printable || !escapeStyle) {
+		return name
+	}
+
+	// A name with single quotes but nothing the shell expands inside double quotes reads better in them
+	if (printable || !escapeStyle) && strings.Contains(name, "'") && !strings.ContainsAny(name, "\"$`\\!") {
+		return `"` + name + `"`
+	}
+
+	var quoted strings.Builder
+	quoted.WriteByte('\'')
+	for idx := 0; idx < len(name); {
+		char, size := utf8.DecodeRuneInString(name[idx:])
+
+		switch {
+		case char == '\'':
+			quoted.WriteString(`'\''`)
+		case escapeStyle && !IsPrintableRune(char, size, utf8Locale):
+			quoted.WriteString("'$'" + CEscapeName(name[idx:idx+size], "", utf8Locale) + "''")
+		default:
+			quoted.WriteString(name[idx : idx+size])
+		}
+		idx += size
+	}
+	quoted.WriteByte('\'')
+
+	// Drop the empty quotes left around escape sequences at either end
+	result := quoted.String()
+	result = strings.TrimPrefix(result, "''")
+	result = strings.TrimSuffix(result, "''")
+	return result
+}
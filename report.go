@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A directory of an HTML report, holding the sections of the subdirectories listed by -R
+type ReportSection struct {
+	Dir      string
+	Columns  []Column
+	Rows     [][]ReportCell
+	Children []*ReportSection
+}
+
+// A cell of an HTML report table
+type ReportCell struct {
+	Text  string
+	Sort  string       // Value the table is sorted on when the column title is clicked
+	Class string       // CSS classes, num for right aligned columns, dir and exec for names
+	Link  template.URL // Only set for the name column
+}
+
+// The sections of the HTML report, built while listing and printed at the end by PrintHTMLReport
+var reportRoot = &ReportSection{}
+
+// The section the directories listed next are added to
+var reportParent = reportRoot
+
+// Characters escaped with a backslash in Markdown table cells
+const markdownSpecials = "\\`*_[]<>|~&!"
+
+// The page PrintHTMLReport prints, the file name colors match GetColorFilename
+const reportTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: monospace; }
+table { border-collapse: collapse; margin: 0.5em 0; }
+th { cursor: pointer; text-align: left; border-bottom: 1px solid #888; user-select: none; }
+th, td { padding: 0 0.75em; white-space: pre; }
+.num { text-align: right; }
+a { color: inherit; text-decoration: none; }
+a:hover { text-decoration: underline; }
+.dir { color: #3465a4; font-weight: bold; }
+.exec { color: #4e9a06; font-weight: bold; }
+details details { margin-left: 1.5em; }
+summary { cursor: pointer; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Sections}}{{template "section" .}}{{end}}
+<script>
+document.querySelectorAll("table.listing th").forEach(function (th) {
+  th.addEventListener("click", function () {
+    var body = th.closest("table").tBodies[0];
+    var col = th.cellIndex;
+    var numeric = th.classList.contains("num");
+    var ascending = th.dataset.order !== "asc";
+    th.dataset.order = ascending ? "asc" : "desc";
+    Array.from(body.rows).sort(function (a, b) {
+      var x = a.cells[col].dataset.sort, y = b.cells[col].dataset.sort;
+      var order = numeric ? parseFloat(x) - parseFloat(y) : x.localeCompare(y);
+      return ascending ? order : -order;
+    }).forEach(function (row) { body.appendChild(row); });
+  });
+});
+</script>
+</body>
+</html>
+{{define "section"}}<details open>
+<summary>{{.Dir}}</summary>
+<table class="listing">
+<thead><tr>{{range .Columns}}<th{{if .AlignRight}} class="num"{{end}}>{{.Header}}</th>{{end}}</tr></thead>
+<tbody>
+{{range .Rows}}<tr>{{range .}}<td{{if .Class}} class="{{.Class}}"{{end}} data-sort="{{.Sort}}">{{if .Link}}<a href="{{.Link}}">{{.Text}}</a>{{else}}{{.Text}}{{end}}</td>{{end}}</tr>
+{{end}}</tbody>
+</table>
+{{range .Children}}{{template "section" .}}{{end}}</details>
+{{end}}`
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: PrintHTMLListing                                                                     *
+*                                                                                            *
+* Description: Adds the entries of a directory to the HTML report as a table with the columns*
+*              of the long listing format. The subdirectories listed by -R are nested in its *
+*              section so the page shows a tree. Nothing is printed until PrintHTMLReport    *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags        - The command line arguments for the program        *
+*              filesInfo : []fs.FileInfo - The slice of files to print                       *
+*              callingDir: string        - The directory the files are in                    *
+*              depth : int               - The depth of callingDir, 0 for the listed         *
+*                                          directory                                         *
+*                                                                                            *
+* return: none                                                                               *
+**********************************************************************************************/
+func PrintHTMLListing(ArgsFlags *Flags, filesInfo []fs.FileInfo, callingDir string, depth int) {
+	filesInfo, dirs, listed := StartListing(ArgsFlags, filesInfo, callingDir, depth, PrintHTMLListing)
+	if !listed {
+		return
+	}
+
+	section := &ReportSection{Dir: callingDir, Columns: ArgsFlags.LongColumns}
+	for _, group := range GroupFiles(ArgsFlags, filesInfo) {
+		for _, info := range group.Files {
+			entry := GetColumnEntry(ArgsFlags.LongColumns, info, callingDir)
+
+			row := make([]ReportCell, 0, len(ArgsFlags.LongColumns))
+			for _, column := range ArgsFlags.LongColumns {
+				row = append(row, GetReportCell(ArgsFlags, column, entry))
+			}
+			section.Rows = append(section.Rows, row)
+		}
+	}
+	reportParent.Children = append(reportParent.Children, section)
+
+	parent := reportParent
+	reportParent = section
+	ListSubDirs(ArgsFlags, dirs, callingDir, depth, PrintHTMLListing)
+	reportParent = parent
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetReportCell                                                                        *
+*                                                                                            *
+* Description: Builds a cell of the HTML report. Right aligned columns sort on their number, *
+*              sizes printed with -h included, and the name links to the file relative to the*
+*              listed directory                                                              *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags   - The command line arguments for the program             *
+*              column : Column      - The column of the cell                                 *
+*              entry : *ColumnEntry - The file of the row                                    *
+*                                                                                            *
+* return: ReportCell - the cell                                                              *
+**********************************************************************************************/
+func GetReportCell(ArgsFlags *Flags, column Column, entry *ColumnEntry) ReportCell {
+	cell := ReportCell{Text: column.Value(ArgsFlags, entry)}
+	cell.Sort = cell.Text
+
+	if column.AlignRight {
+		cell.Class = "num"
+		if size, err := ParseReadableSize(cell.Text); err == nil {
+			cell.Sort = fmt.Sprint(size)
+		}
+	}
+
+	if column.Name == "name" {
+		if entry.Info.IsDir() {
+			cell.Class = "dir"
+		} else if entry.Info.Mode()&os.ModePerm&0100 != 0 {
+			cell.Class = "exec"
+		}
+
+		// Each path element is escaped so names holding # ? or : stay part of the path
+		path := JoinPath(entry.Dir, entry.Info.Name())
+		if strings.HasPrefix(path, ArgsFlags.Path+"/") {
+			path = "./" + strings.TrimPrefix(path, ArgsFlags.Path+"/")
+		} else if !filepath.IsAbs(path) {
+			path = "./" + path
+		}
+
+		segments := strings.Split(path, "/")
+		for idx := range segments {
+			segments[idx] = url.PathEscape(segments[idx])
+		}
+		cell.Link = template.URL(strings.Join(segments, "/"))
+	}
+
+	return cell
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: PrintHTMLReport                                                                      *
+*                                                                                            *
+* Description: Prints the HTML report built by PrintHTMLListing as a page that needs no other*
+*              file                                                                          *
+*                                                                                            *
+* Parameters: ArgsFlags : *Flags - The command line arguments for the program                *
+*                                                                                            *
+* return: none                                                                               *
+**********************************************************************************************/
+func PrintHTMLReport(ArgsFlags *Flags) {
+	page := template.Must(template.New("report").Parse(reportTemplate))
+	data := struct {
+		Title    string
+		Sections []*ReportSection
+	}{"Index of " + ArgsFlags.Path, reportRoot.Children}
+
+	if err := page.Execute(os.Stdout, data); err != nil {
+		fmt.Fprintf(os.Stderr, "vls: %s\n", err)
+		exitStatus = max(exitStatus, 1)
+	}
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: PrintMarkdownListing                                                                 *
+*                                                                                            *
+* Description: Prints the entries of a directory as a GitHub flavored Markdown table with the*
+*              columns of the long listing format. With -R each directory gets a heading and *
+*              its own table                                                                 *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags        - The command line arguments for the program        *
+*              filesInfo : []fs.FileInfo - The slice of files to print                       *
+*              callingDir: string        - The directory the files are in                    *
+*              depth : int               - The depth of callingDir, 0 for the listed         *
+*                                          directory                                         *
+*                                                                                            *
+* return: none                                                                               *
+**********************************************************************************************/
+func PrintMarkdownListing(ArgsFlags *Flags, filesInfo []fs.FileInfo, callingDir string, depth int) {
+	filesInfo, dirs, listed := StartListing(ArgsFlags, filesInfo, callingDir, depth, PrintMarkdownListing)
+	if !listed {
+		return
+	}
+
+	if sectionsListed > 0 {
+		fmt.Println()
+	}
+	if depth > 0 {
+		fmt.Printf("### %s\n\n", EscapeMarkdown(callingDir))
+	}
+	sectionsListed++
+
+	header, align := "|", "|"
+	for _, column := range ArgsFlags.LongColumns {
+		header += " " + EscapeMarkdown(column.Header) + " |"
+		if column.AlignRight {
+			align += " ---: |"
+		} else {
+			align += " --- |"
+		}
+	}
+	fmt.Println(header)
+	fmt.Println(align)
+
+	for _, group := range GroupFiles(ArgsFlags, filesInfo) {
+		for _, info := range group.Files {
+			entry := GetColumnEntry(ArgsFlags.LongColumns, info, callingDir)
+
+			row := "|"
+			for _, column := range ArgsFlags.LongColumns {
+				row += " " + EscapeMarkdown(column.Value(ArgsFlags, entry)) + " |"
+			}
+			fmt.Println(row)
+		}
+	}
+
+	ListSubDirs(ArgsFlags, dirs, callingDir, depth, PrintMarkdownListing)
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: EscapeMarkdown                                                                       *
+*                                                                                            *
+* Description: Escapes text for a Markdown table cell: characters that start emphasis, links,*
+*              HTML or end the cell get a backslash, control characters such as line breaks  *
+*              and escape sequences become HTML character references and bytes that are not  *
+*              valid UTF-8 become octal escapes                                              *
+*                                                                                            *
+* Parameters: text : string - The text of the cell                                           *
+*                                                                                            *
+* return: string - the escaped text                                                          *
+**********************************************************************************************/
+func EscapeMarkdown(text string) string {
+	var escaped strings.Builder
+	for len(text) > 0 {
+		char, size := utf8.DecodeRuneInString(text)
+		switch {
+		case char == utf8.RuneError && size == 1:
+			fmt.Fprintf(&escaped, "\\%03o", text[0])
+		case strings.ContainsRune(markdownSpecials, char):
+			escaped.WriteRune('\\')
+			escaped.WriteRune(char)
+		case unicode.IsControl(char):
+			fmt.Fprintf(&escaped, "&#%d;", char)
+		default:
+			escaped.WriteRune(char)
+		}
+		text = text[size:]
+	}
+
+	return escaped.String()
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEscapeMarkdown(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"plain.txt", "plain.txt"},
+		{"a|b*c_d", `a\|b\*c\_d`},
+		{"two\nlines\r", "two&#10;lines&#13;"},
+		{"evil\033]0;x\a\rname", "evil&#27;\\]0;x&#7;&#13;name"},
+		{"tab\there\u009b", "tab&#9;here&#155;"},
+		{"bad\xff\xfeutf8", `bad\377\376utf8`},
+		{"café", "café"},
+	}
+
+	for _, test := range tests {
+		if got := EscapeMarkdown(test.text); got != test.want {
+			t.Errorf("EscapeMarkdown(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestMarkdownControlCharacters(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "evil\033]0;x\a\rname\xff", "")
+
+	stdout, stderr, status := runVls(t, dir, "--format=markdown")
+	if status != 0 {
+		t.Fatalf("vls exited with %d: %s", status, stderr)
+	}
+
+	if strings.ContainsAny(stdout, "\033\a\r") || !strings.Contains(stdout, "evil&#27;\\]0;x&#7;&#13;name\\377") {
+		t.Errorf("the name is not escaped in:\n%q", stdout)
+	}
+}
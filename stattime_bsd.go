@@ -0,0 +1,24 @@
+//go:build darwin || freebsd || netbsd
+
+package main
+
+import (
+	"syscall"
+	"time"
+)
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetAccessChangeTimes                                                                 *
+*                                                                                            *
+* Description: Returns the access and status change times of stat data. These systems name   *
+*              the fields Atimespec and Ctimespec                                            *
+*                                                                                            *
+* Parameters: stat : *syscall.Stat_t - The stat data of a file                               *
+*                                                                                            *
+* return: time.Time - the access time                                                        *
+*         time.Time - the status change time                                                 *
+**********************************************************************************************/
+func GetAccessChangeTimes(stat *syscall.Stat_t) (time.Time, time.Time) {
+	return time.Unix(int64(stat.Atimespec.Sec), int64(stat.Atimespec.Nsec)), time.Unix(int64(stat.Ctimespec.Sec), int64(stat.Ctimespec.Nsec))
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// Totals gathered over every directory listed, printed by --summary
+type ListingSummary struct {
+	Files       int
+	Dirs        int
+	Symlinks    int
+	Others      int
+	Hidden      int   // Dotfiles found, whether they were listed or not
+	Apparent    int64 // Sum of the sizes of the listed entries
+	Allocated   int64 // Disk space used by the listed entries
+	Largest     fs.FileInfo
+	LargestPath string
+	Newest      fs.FileInfo
+	NewestPath  string
+}
+
+// The summary of the current run, filled in by the listing functions
+var listingSummary ListingSummary
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: Add                                                                                  *
+*                                                                                            *
+* Description: Adds the entries of one directory to the summary. The . and .. entries are not*
+*              counted. The contents of a directory -R descends into are added when it is    *
+*              listed, so such a directory only adds its own size and not the total of       *
+*              --dir-size                                                                    *
+*                                                                                            *
+* Parameters:  allFiles : []fs.FileInfo - Every entry of the directory, used to count        *
+*                                         dotfiles                                           *
+*              shown : []fs.FileInfo    - The entries left after filtering                   *
+*              dirs : []fs.FileInfo     - The subdirectories -R descends into                *
+*              callingDir : string      - The directory the files are in                     *
+*                                                                                            *
+* return: none                                                                               *
+**********************************************************************************************/
+func (summary *ListingSummary) Add(allFiles []fs.FileInfo, shown []fs.FileInfo, dirs []fs.FileInfo, callingDir string) {
+	for _, info := range allFiles {
+		if strings.HasPrefix(info.Name(), ".") && !IsDotEntry(info.Name()) {
+			summary.Hidden++
+		}
+	}
+
+	descended := make(map[string]bool, len(dirs))
+	for _, dir := range dirs {
+		descended[dir.Name()] = true
+	}
+
+	for _, info := range shown {
+		if IsDotEntry(info.Name()) {
+			continue
+		}
+
+		switch GetFileTypeChar(info.Mode()) {
+		case 'f':
+			summary.Files++
+		case 'd':
+			summary.Dirs++
+		case 'l':
+			summary.Symlinks++
+		default:
+			summary.Others++
+		}
+
+		if sized, ok := info.(DirSizeInfo); ok && descended[info.Name()] {
+			summary.Apparent += sized.FileInfo.Size()
+			summary.Allocated += GetAllocatedSize(sized.FileInfo)
+		} else {
+			summary.Apparent += info.Size()
+			summary.Allocated += GetAllocatedSize(info)
+		}
+
+		if summary.Largest == nil || info.Size() > summary.Largest.Size() {
+			summary.Largest, summary.LargestPath = info, JoinPath(callingDir, info.Name())
+		}
+		if summary.Newest == nil || info.ModTime().After(summary.Newest.ModTime()) {
+			summary.Newest, summary.NewestPath = info, JoinPath(callingDir, info.Name())
+		}
+	}
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: Print                                                                                *
+*                                                                                            *
+* Description: Prints the summary after the listing, with paths relative to the listed       *
+*              directory                                                                     *
+*                                                                                            *
+* Parameters: ArgsFlags : *Flags - The command line arguments for the program                *
+*                                                                                            *
+* return: none                                                                               *
+**********************************************************************************************/
+func (summary *ListingSummary) Print(ArgsFlags *Flags) {
+	fmt.Println()
+	fmt.Printf("%s, %s, %s, %s, %d hidden\n",
+		Plural(summary.Files, "file", "files"), Plural(summary.Dirs, "directory", "directories"),
+		Plural(summary.Symlinks, "symbolic link", "symbolic links"), Plural(summary.Others, "other", "others"), summary.Hidden)
+	fmt.Printf("Size: %s apparent, %s allocated\n", FormatSize(ArgsFlags, summary.Apparent), FormatSize(ArgsFlags, summary.Allocated))
+
+	if summary.Largest != nil {
+		fmt.Printf("Largest: %s (%s)\n", FormatFilename(ArgsFlags, strings.TrimPrefix(summary.LargestPath, ArgsFlags.Path+"/")), FormatSize(ArgsFlags, summary.Largest.Size()))
+		fmt.Printf("Newest: %s (%s)\n", FormatFilename(ArgsFlags, strings.TrimPrefix(summary.NewestPath, ArgsFlags.Path+"/")), summary.Newest.ModTime().Format(ArgsFlags.TimeFormat))
+	}
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: Plural                                                                               *
+*                                                                                            *
+* Description: Formats a count followed by the singular or plural form of a noun             *
+*                                                                                            *
+* Parameters:  count : int       - The count                                                 *
+*              singular : string - The noun for one                                          *
+*              plural : string   - The noun for any other count                              *
+*                                                                                            *
+* return: string - such as 1 file or 3 files                                                 *
+**********************************************************************************************/
+func Plural(count int, singular string, plural string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, singular)
+	}
+
+	return fmt.Sprintf("%d %s", count, plural)
+}
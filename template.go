@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// The fields of an entry available to --template, such as {{.Name}} or {{.Size | human}}
+type TemplateEntry struct {
+	Name    string
+	Path    string // The directory and the name, as they would be typed
+	Dir     string
+	Ext     string
+	Type    string // One letter: f, d, l, p, s, b or c
+	Mode    fs.FileMode
+	Size    int64
+	ModTime time.Time
+	Owner   string
+	Group   string
+	Links   uint64
+	Inode   uint64
+	IsDir   bool
+	Info    fs.FileInfo
+}
+
+// The fields of a directory available to --template-header and --template-footer
+type TemplateSection struct {
+	Dir     string
+	Depth   int
+	Count   int
+	Total   int64 // Sum of the sizes of the entries
+	Entries []TemplateEntry
+}
+
+// Colors the color template function accepts
+var templateColors = map[string]string{
+	"bold": BOLD, "underline": UNDERLINE, "red": RED, "green": GREEN, "yellow": YELLOW,
+	"blue": BLUE, "purple": PURPLE, "cyan": CYAN, "grey": GREY,
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: ParseTemplates                                                                       *
+*                                                                                            *
+* Description: Builds the template given with --template or --template-file, along with the  *
+*              header and footer templates. The entry template is the main template, the     *
+*              header and footer are the templates it defines as header and footer, so a     *
+*              template file may hold all three. A newline is added after each of them, so   *
+*              one at the end of a file is dropped                                           *
+*                                                                                            *
+* Parameters: ArgsFlags : *Flags - The command line arguments for the program                *
+*                                                                                            *
+* return: *template.Template - the templates, nil if no template was given                   *
+*         error              - non-nil if a file cannot be read or a template does not parse *
+**********************************************************************************************/
+func ParseTemplates(ArgsFlags *Flags) (*template.Template, error) {
+	text := *ArgsFlags.Template
+	if *ArgsFlags.TemplateFile != "" {
+		content, err := os.ReadFile(*ArgsFlags.TemplateFile)
+		if err != nil {
+			return nil, err
+		}
+		text = string(content)
+	}
+	if text == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New("entry").Funcs(GetTemplateFuncs(ArgsFlags)).Parse(strings.TrimSuffix(text, "\n"))
+	if err != nil {
+		return nil, err
+	}
+
+	for name, text := range map[string]string{"header": *ArgsFlags.TemplateHeader, "footer": *ArgsFlags.TemplateFooter} {
+		if text == "" {
+			continue
+		}
+		if _, err := tmpl.New(name).Parse(strings.TrimSuffix(text, "\n")); err != nil {
+			return nil, err
+		}
+	}
+
+	return tmpl, nil
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetTemplateFuncs                                                                     *
+*                                                                                            *
+* Description: Returns the functions templates can call: human formats a size, time formats a*
+*              time with a Go layout, color wraps text in a color unless -G is given, join   *
+*              joins path elements and quote quotes text for the shell when needed           *
+*                                                                                            *
+* Parameters: ArgsFlags : *Flags - The command line arguments for the program                *
+*                                                                                            *
+* return: template.FuncMap - the functions by name                                           *
+**********************************************************************************************/
+func GetTemplateFuncs(ArgsFlags *Flags) template.FuncMap {
+	return template.FuncMap{
+		"human": GetReadableSize,
+		"time": func(layout string, t time.Time) string {
+			return t.Format(layout)
+		},
+		"color": func(name string, text string) (string, error) {
+			code, ok := templateColors[name]
+			if !ok {
+				return "", fmt.Errorf("unknown color %q", name)
+			}
+			if *ArgsFlags.NoColors {
+				return text, nil
+			}
+			return code + text + RESET, nil
+		},
+		"join": filepath.Join,
+		"quote": func(text string) string {
+			return ShellQuoteName(text, "shell-escape", false, IsUTF8Locale())
+		},
+	}
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: NewTemplateEntry                                                                     *
+*                                                                                            *
+* Description: Gathers the fields of a file for the entry template                           *
+*                                                                                            *
+* Parameters:  info : fs.FileInfo  - The file                                                *
+*              callingDir : string - The directory it is in                                  *
+*                                                                                            *
+* return: TemplateEntry - the fields                                                         *
+**********************************************************************************************/
+func NewTemplateEntry(info fs.FileInfo, callingDir string) TemplateEntry {
+	return TemplateEntry{
+		Name:    info.Name(),
+		Path:    JoinPath(callingDir, info.Name()),
+		Dir:     callingDir,
+		Ext:     GetExtension(info.Name()),
+		Type:    string(GetFileTypeChar(info.Mode())),
+		Mode:    info.Mode(),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Owner:   GetOwnerName(info),
+		Group:   GetGroupName(info),
+		Links:   GetStatField(info, "nlink"),
+		Inode:   GetStatField(info, "inode"),
+		IsDir:   info.IsDir(),
+		Info:    info,
+	}
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: PrintTemplateListing                                                                 *
+*                                                                                            *
+* Description: Prints the entries of a directory with --template, one rendered entry per     *
+*              line, between the rendered header and footer. With -R every directory gets its*
+*              own header and footer                                                         *
+*                                                                                            *
+* Parameters:  ArgsFlags : *Flags        - The command line arguments for the program        *
+*              filesInfo : []fs.FileInfo - The slice of files to print                       *
+*              callingDir: string        - The directory the files are in                    *
+*              depth : int               - The depth of callingDir, 0 for the listed         *
+*                                          directory                                         *
+*                                                                                            *
+* return: none                                                                               *
+**********************************************************************************************/
+func PrintTemplateListing(ArgsFlags *Flags, filesInfo []fs.FileInfo, callingDir string, depth int) {
+	filesInfo, dirs, listed := StartListing(ArgsFlags, filesInfo, callingDir, depth, PrintTemplateListing)
+	if !listed {
+		return
+	}
+
+	section := TemplateSection{Dir: callingDir, Depth: depth}
+	for _, group := range GroupFiles(ArgsFlags, filesInfo) {
+		for _, info := range group.Files {
+			section.Entries = append(section.Entries, NewTemplateEntry(info, callingDir))
+			section.Total += info.Size()
+		}
+	}
+	section.Count = len(section.Entries)
+
+	ExecuteTemplate(ArgsFlags.EntryTemplate.Lookup("header"), section)
+	for _, entry := range section.Entries {
+		ExecuteTemplate(ArgsFlags.EntryTemplate, entry)
+	}
+	ExecuteTemplate(ArgsFlags.EntryTemplate.Lookup("footer"), section)
+
+	ListSubDirs(ArgsFlags, dirs, callingDir, depth, PrintTemplateListing)
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: ExecuteTemplate                                                                      *
+*                                                                                            *
+* Description: Renders a template to stdout followed by a newline. A template failing on an  *
+*              entry stops the program, as every other entry would most likely fail the same *
+*              way                                                                           *
+*                                                                                            *
+* Parameters:  tmpl : *template.Template - The template, nothing is printed if it is nil     *
+*              data : any                - The entry or section to render                    *
+*                                                                                            *
+* return: none                                                                               *
+**********************************************************************************************/
+func ExecuteTemplate(tmpl *template.Template, data any) {
+	if tmpl == nil {
+		return
+	}
+
+	if err := tmpl.Execute(os.Stdout, data); err != nil {
+		fmt.Fprintf(os.Stderr, "vls: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Println()
+}
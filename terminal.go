@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// Layout of struct winsize filled in by the TIOCGWINSZ ioctl
+type winSize struct {
+	Rows    uint16
+	Columns uint16
+	XPixels uint16
+	YPixels uint16
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetTerminalWidth                                                                     *
+*                                                                                            *
+* Description: Returns the number of columns of the terminal stdout is connected to. When    *
+*              stdout is not a terminal the COLUMNS environment variable is used, and 80 when*
+*              that is not set either                                                        *
+*                                                                                            *
+* Parameters: none                                                                           *
+*                                                                                            *
+* return: int - the width in columns                                                         *
+**********************************************************************************************/
+func GetTerminalWidth() int {
+	var size winSize
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, os.Stdout.Fd(), syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&size)))
+	if errno == 0 && size.Columns > 0 {
+		return int(size.Columns)
+	}
+
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+
+	return 80
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: IsUTF8Locale                                                                         *
+*                                                                                            *
+* Description: Tells whether the locale uses UTF-8, in which case non ASCII characters such  *
+*              as block elements can be printed. The first of LC_ALL, LC_CTYPE and LANG that *
+*              is set decides, as in the C library                                           *
+*                                                                                            *
+* Parameters: none                                                                           *
+*                                                                                            *
+* return: bool                                                                               *
+**********************************************************************************************/
+func IsUTF8Locale() bool {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if locale := strings.ToUpper(os.Getenv(name)); locale != "" {
+			return strings.Contains(locale, "UTF-8") || strings.Contains(locale, "UTF8")
+		}
+	}
+
+	return false
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: IsTerminal                                                                           *
+*                                                                                            *
+* Description: Tells whether a file is a terminal, by asking for its terminal attributes     *
+*                                                                                            *
+* Parameters: file : *os.File - The file to test                                             *
+*                                                                                            *
+* return: bool                                                                               *
+**********************************************************************************************/
+func IsTerminal(file *os.File) bool {
+	var termios syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), IOCTL_GET_TERMIOS, uintptr(unsafe.Pointer(&termios)))
+	return errno == 0
+}
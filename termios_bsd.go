@@ -0,0 +1,8 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package main
+
+import "syscall"
+
+// The ioctl request that reads the attributes of a terminal, used by IsTerminal
+const IOCTL_GET_TERMIOS = syscall.TIOCGETA
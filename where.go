@@ -0,0 +1,683 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// The kinds of values a --where field holds, which decide the operators and literals it takes
+type WhereType int
+
+const (
+	WHERE_NUMBER   WhereType = iota // Plain counts such as nlink or uid
+	WHERE_SIZE                      // Bytes, literals may use the units of GetReadableSize
+	WHERE_AGE                       // Time passed since a timestamp, literals are durations like 7d
+	WHERE_STRING                    // Text compared exactly or with a regular expression
+	WHERE_FILETYPE                  // One of the type letters of GetFileTypeChar
+	WHERE_PERM                      // Permission bits, literals are octal or symbolic like o+w
+)
+
+// A file a --where expression is evaluated against
+type WhereEntry struct {
+	Info fs.FileInfo
+	Dir  string
+	Now  time.Time
+}
+
+// A field that can be used on the left hand side of a comparison. Numeric fields use
+// Number, the others Text
+type WhereField struct {
+	Type   WhereType
+	Number func(entry *WhereEntry) int64
+	Text   func(entry *WhereEntry) string
+}
+
+// A node of a parsed --where expression. Op is &&, || or ! for the logical operators,
+// anything else is a comparison between Field and Value
+type WhereNode struct {
+	Op          string
+	Left        *WhereNode
+	Right       *WhereNode
+	Field       string
+	Value       string
+	ValueQuoted bool
+	Pos         int // Column of the node in the expression, used in error messages
+	OpPos       int // Column of the comparison operator
+	ValuePos    int // Column of the value compared against
+	test        func(entry *WhereEntry) bool
+}
+
+// A token of a --where expression
+type whereToken struct {
+	kind string // word, string, op or end
+	text string
+	pos  int
+}
+
+// Reads the tokens of an expression one at a time for the parser
+type whereParser struct {
+	tokens []whereToken
+	next   int
+}
+
+// The fields --where knows about
+var whereFields = map[string]WhereField{
+	"name":  {Type: WHERE_STRING, Text: func(entry *WhereEntry) string { return entry.Info.Name() }},
+	"path":  {Type: WHERE_STRING, Text: func(entry *WhereEntry) string { return JoinPath(entry.Dir, entry.Info.Name()) }},
+	"ext":   {Type: WHERE_STRING, Text: func(entry *WhereEntry) string { return GetExtension(entry.Info.Name()) }},
+	"user":  {Type: WHERE_STRING, Text: func(entry *WhereEntry) string { return GetOwnerName(entry.Info) }},
+	"group": {Type: WHERE_STRING, Text: func(entry *WhereEntry) string { return GetGroupName(entry.Info) }},
+	"type": {Type: WHERE_FILETYPE, Text: func(entry *WhereEntry) string {
+		return string(GetFileTypeChar(entry.Info.Mode()))
+	}},
+	"size": {Type: WHERE_SIZE, Number: func(entry *WhereEntry) int64 { return entry.Info.Size() }},
+	"perm": {Type: WHERE_PERM, Number: func(entry *WhereEntry) int64 { return int64(GetUnixMode(entry.Info)) }},
+	"mtime": {Type: WHERE_AGE, Number: func(entry *WhereEntry) int64 {
+		return int64(entry.Now.Sub(entry.Info.ModTime()))
+	}},
+	"atime": {Type: WHERE_AGE, Number: func(entry *WhereEntry) int64 {
+		return int64(entry.Now.Sub(GetStatTime(entry.Info, 'a')))
+	}},
+	"ctime": {Type: WHERE_AGE, Number: func(entry *WhereEntry) int64 {
+		return int64(entry.Now.Sub(GetStatTime(entry.Info, 'c')))
+	}},
+	"nlink": {Type: WHERE_NUMBER, Number: func(entry *WhereEntry) int64 { return int64(GetStatField(entry.Info, "nlink")) }},
+	"inode": {Type: WHERE_NUMBER, Number: func(entry *WhereEntry) int64 { return int64(GetStatField(entry.Info, "inode")) }},
+	"uid":   {Type: WHERE_NUMBER, Number: func(entry *WhereEntry) int64 { return int64(GetStatField(entry.Info, "uid")) }},
+	"gid":   {Type: WHERE_NUMBER, Number: func(entry *WhereEntry) int64 { return int64(GetStatField(entry.Info, "gid")) }},
+}
+
+// Every operator that can follow a field name, = is read as ==
+var whereComparisons = map[string]bool{
+	"==": true, "=": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true, "=~": true, "!~": true, "&": true,
+}
+
+// The comparison operators each kind of field accepts
+var whereOperators = map[WhereType][]string{
+	WHERE_NUMBER:   {"==", "!=", "<", "<=", ">", ">="},
+	WHERE_SIZE:     {"==", "!=", "<", "<=", ">", ">="},
+	WHERE_AGE:      {"==", "!=", "<", "<=", ">", ">="},
+	WHERE_STRING:   {"==", "!=", "=~", "!~"},
+	WHERE_FILETYPE: {"==", "!="},
+	WHERE_PERM:     {"==", "!=", "&"},
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: FilterWhere                                                                          *
+*                                                                                            *
+* Description: Removes the files that do not match a --where expression from the passed in   *
+*              slice and returns a new slice without those files in it                       *
+*                                                                                            *
+* Parameters:  expr : *WhereNode         - The checked expression                            *
+*              filesInfo : []fs.FileInfo - The files to filter                               *
+*              callingDir : string       - The directory the files are in                    *
+*                                                                                            *
+* return: []fs.FileInfo - The filtered slice                                                 *
+**********************************************************************************************/
+func FilterWhere(expr *WhereNode, filesInfo []fs.FileInfo, callingDir string) []fs.FileInfo {
+	now := time.Now()
+
+	kept := make([]fs.FileInfo, 0, len(filesInfo))
+	for _, file := range filesInfo {
+		if expr.Eval(&WhereEntry{Info: file, Dir: callingDir, Now: now}) {
+			kept = append(kept, file)
+		}
+	}
+
+	return kept
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: ParseWhere                                                                           *
+*                                                                                            *
+* Description: Parses and type checks a --where expression. Comparisons are joined with &&,  *
+*              || and ! and grouped with parentheses, && binding tighter than ||             *
+*                                                                                            *
+* Parameters: text : string - The expression                                                 *
+*                                                                                            *
+* return: *WhereNode - the root of the checked expression                                    *
+*         error      - non-nil with the column of the problem if the expression is invalid   *
+**********************************************************************************************/
+func ParseWhere(text string) (*WhereNode, error) {
+	tokens, err := LexWhere(text)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := &whereParser{tokens: tokens}
+	expr, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if token := parser.peek(); token.kind != "end" {
+		return nil, fmt.Errorf("column %d: unexpected %q, expected && or ||", token.pos, token.text)
+	}
+
+	if err := expr.Check(); err != nil {
+		return nil, err
+	}
+
+	return expr, nil
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: LexWhere                                                                             *
+*                                                                                            *
+* Description: Splits a --where expression into words, quoted strings and operators. Words   *
+*              run until whitespace, a quote, a parenthesis or an operator character, so     *
+*              literals like 10M, o+w and *.log need no quotes                               *
+*                                                                                            *
+* Parameters: text : string - The expression                                                 *
+*                                                                                            *
+* return: []whereToken - the tokens, ending with an end token                                *
+*         error        - non-nil for unterminated strings and stray characters               *
+**********************************************************************************************/
+func LexWhere(text string) ([]whereToken, error) {
+	operators := []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "<", ">", "=", "!", "&", "(", ")"}
+
+	tokens := make([]whereToken, 0)
+	for pos := 0; pos < len(text); {
+		char := text[pos]
+		if char == ' ' || char == '\t' || char == '\n' {
+			pos++
+			continue
+		}
+
+		// Quoted strings, a backslash escapes the next character
+		if char == '\'' || char == '"' {
+			var value strings.Builder
+			end := pos + 1
+			for end < len(text) && text[end] != char {
+				if text[end] == '\\' && end+1 < len(text) && (text[end+1] == char || text[end+1] == '\\') {
+					end++
+				}
+				value.WriteByte(text[end])
+				end++
+			}
+			if end >= len(text) {
+				return nil, fmt.Errorf("column %d: unterminated string", pos+1)
+			}
+
+			tokens = append(tokens, whereToken{kind: "string", text: value.String(), pos: pos + 1})
+			pos = end + 1
+			continue
+		}
+
+		matched := false
+		for _, operator := range operators {
+			if strings.HasPrefix(text[pos:], operator) {
+				tokens = append(tokens, whereToken{kind: "op", text: operator, pos: pos + 1})
+				pos += len(operator)
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+
+		if char == '|' {
+			return nil, fmt.Errorf("column %d: unexpected |, did you mean ||?", pos+1)
+		}
+
+		end := pos
+		for end < len(text) && !strings.ContainsRune(" \t\n'\"()!=<>&|", rune(text[end])) {
+			end++
+		}
+		tokens = append(tokens, whereToken{kind: "word", text: text[pos:end], pos: pos + 1})
+		pos = end
+	}
+
+	return append(tokens, whereToken{kind: "end", text: "end of expression", pos: len(text) + 1}), nil
+}
+
+// Returns the next token without consuming it
+func (parser *whereParser) peek() whereToken {
+	return parser.tokens[parser.next]
+}
+
+// Consumes and returns the next token
+func (parser *whereParser) advance() whereToken {
+	token := parser.tokens[parser.next]
+	if token.kind != "end" {
+		parser.next++
+	}
+	return token
+}
+
+// or := and ( "||" and )*
+func (parser *whereParser) parseOr() (*WhereNode, error) {
+	left, err := parser.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for parser.peek().text == "||" && parser.peek().kind == "op" {
+		operator := parser.advance()
+		right, err := parser.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &WhereNode{Op: "||", Left: left, Right: right, Pos: operator.pos}
+	}
+
+	return left, nil
+}
+
+// and := unary ( "&&" unary )*
+func (parser *whereParser) parseAnd() (*WhereNode, error) {
+	left, err := parser.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for parser.peek().text == "&&" && parser.peek().kind == "op" {
+		operator := parser.advance()
+		right, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &WhereNode{Op: "&&", Left: left, Right: right, Pos: operator.pos}
+	}
+
+	return left, nil
+}
+
+// unary := "!" unary | "(" or ")" | field operator value
+func (parser *whereParser) parseUnary() (*WhereNode, error) {
+	token := parser.advance()
+
+	if token.kind == "op" && token.text == "!" {
+		operand, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &WhereNode{Op: "!", Left: operand, Pos: token.pos}, nil
+	}
+
+	if token.kind == "op" && token.text == "(" {
+		expr, err := parser.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := parser.advance(); closing.text != ")" || closing.kind != "op" {
+			return nil, fmt.Errorf("column %d: expected ) to close the ( at column %d, got %q", closing.pos, token.pos, closing.text)
+		}
+		return expr, nil
+	}
+
+	if token.kind != "word" {
+		return nil, fmt.Errorf("column %d: expected a field name, got %q", token.pos, token.text)
+	}
+
+	operator := parser.advance()
+	if operator.kind != "op" || !whereComparisons[operator.text] {
+		return nil, fmt.Errorf("column %d: expected a comparison after %s, got %q", operator.pos, token.text, operator.text)
+	}
+	if operator.text == "=" {
+		operator.text = "=="
+	}
+
+	value := parser.advance()
+	if value.kind != "word" && value.kind != "string" {
+		return nil, fmt.Errorf("column %d: expected a value after %s %s, got %q", value.pos, token.text, operator.text, value.text)
+	}
+
+	return &WhereNode{
+		Op:          operator.text,
+		Field:       token.text,
+		Value:       value.text,
+		ValueQuoted: value.kind == "string",
+		Pos:         token.pos,
+		OpPos:       operator.pos,
+		ValuePos:    value.pos,
+	}, nil
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: Check                                                                                *
+*                                                                                            *
+* Description: Type checks an expression: every field must exist, take the operator it is    *
+*              used with and be compared against a literal of its kind. Comparisons are      *
+*              compiled into tests while checking so Eval does no parsing                    *
+*                                                                                            *
+* Parameters: none                                                                           *
+*                                                                                            *
+* return: error - non-nil with the column of the first problem                               *
+**********************************************************************************************/
+func (node *WhereNode) Check() error {
+	switch node.Op {
+	case "&&", "||":
+		if err := node.Left.Check(); err != nil {
+			return err
+		}
+		return node.Right.Check()
+	case "!":
+		return node.Left.Check()
+	}
+
+	field, ok := whereFields[node.Field]
+	if !ok {
+		names := make([]string, 0, len(whereFields))
+		for name := range whereFields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("column %d: unknown field %q, expected one of %s", node.Pos, node.Field, strings.Join(names, ", "))
+	}
+
+	allowed := false
+	for _, operator := range whereOperators[field.Type] {
+		allowed = allowed || operator == node.Op
+	}
+	if !allowed {
+		return fmt.Errorf("column %d: %s cannot be compared with %s, use one of %s", node.OpPos, node.Field, node.Op, strings.Join(whereOperators[field.Type], " "))
+	}
+
+	switch field.Type {
+	case WHERE_NUMBER, WHERE_SIZE, WHERE_AGE:
+		var literal int64
+		var err error
+		if node.ValueQuoted {
+			err = fmt.Errorf("a number cannot be quoted")
+		} else if field.Type == WHERE_SIZE {
+			literal, err = ParseReadableSize(node.Value)
+		} else if field.Type == WHERE_AGE {
+			var age time.Duration
+			age, err = ParseAge(node.Value)
+			literal = int64(age)
+		} else {
+			literal, err = strconv.ParseInt(node.Value, 10, 64)
+		}
+		if err != nil {
+			return fmt.Errorf("column %d: %s expects %s, got %q", node.ValuePos, node.Field, GetWhereTypeHint(field.Type), node.Value)
+		}
+
+		node.test = func(entry *WhereEntry) bool {
+			return CompareNumbers(field.Number(entry), node.Op, literal)
+		}
+	case WHERE_STRING:
+		if node.Op == "=~" || node.Op == "!~" {
+			compiled, err := regexp.Compile(node.Value)
+			if err != nil {
+				return fmt.Errorf("column %d: invalid regular expression %q: %s", node.ValuePos, node.Value, err)
+			}
+			node.test = func(entry *WhereEntry) bool {
+				return compiled.MatchString(field.Text(entry)) == (node.Op == "=~")
+			}
+		} else {
+			node.test = func(entry *WhereEntry) bool {
+				return (field.Text(entry) == node.Value) == (node.Op == "==")
+			}
+		}
+	case WHERE_FILETYPE:
+		if len(node.Value) != 1 || !strings.Contains("fdlpsbc", node.Value) {
+			return fmt.Errorf("column %d: %s expects %s, got %q", node.ValuePos, node.Field, GetWhereTypeHint(field.Type), node.Value)
+		}
+		node.test = func(entry *WhereEntry) bool {
+			return (field.Text(entry) == node.Value) == (node.Op == "==")
+		}
+	case WHERE_PERM:
+		mask, err := ParsePermissions(node.Value)
+		if err != nil || node.ValueQuoted {
+			return fmt.Errorf("column %d: %s expects %s, got %q", node.ValuePos, node.Field, GetWhereTypeHint(field.Type), node.Value)
+		}
+		node.test = func(entry *WhereEntry) bool {
+			perm := field.Number(entry)
+			switch node.Op {
+			case "&":
+				return perm&mask == mask
+			case "==":
+				return perm == mask
+			}
+			return perm != mask
+		}
+	}
+
+	return nil
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: Eval                                                                                 *
+*                                                                                            *
+* Description: Evaluates a checked expression against a file                                 *
+*                                                                                            *
+* Parameters: entry : *WhereEntry - The file to test                                         *
+*                                                                                            *
+* return: bool - true if the file matches                                                    *
+**********************************************************************************************/
+func (node *WhereNode) Eval(entry *WhereEntry) bool {
+	switch node.Op {
+	case "&&":
+		return node.Left.Eval(entry) && node.Right.Eval(entry)
+	case "||":
+		return node.Left.Eval(entry) || node.Right.Eval(entry)
+	case "!":
+		return !node.Left.Eval(entry)
+	}
+
+	return node.test(entry)
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: CompareNumbers                                                                       *
+*                                                                                            *
+* Description: Applies a comparison operator to two numbers                                  *
+*                                                                                            *
+* Parameters:  left : int64      - The value of the field                                    *
+*              operator : string - One of == != < <= > >=                                    *
+*              right : int64     - The literal                                               *
+*                                                                                            *
+* return: bool                                                                               *
+**********************************************************************************************/
+func CompareNumbers(left int64, operator string, right int64) bool {
+	switch operator {
+	case "==":
+		return left == right
+	case "!=":
+		return left != right
+	case "<":
+		return left < right
+	case "<=":
+		return left <= right
+	case ">":
+		return left > right
+	}
+	return left >= right
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetWhereTypeHint                                                                     *
+*                                                                                            *
+* Description: Describes the literals a kind of field takes, for error messages              *
+*                                                                                            *
+* Parameters: fieldType : WhereType - The kind of field                                      *
+*                                                                                            *
+* return: string                                                                             *
+**********************************************************************************************/
+func GetWhereTypeHint(fieldType WhereType) string {
+	switch fieldType {
+	case WHERE_SIZE:
+		return "a size like 512, 10K or 1.5G"
+	case WHERE_AGE:
+		return "a duration like 30m, 2h or 7d"
+	case WHERE_FILETYPE:
+		return "one of the type letters f, d, l, p, s, b or c"
+	case WHERE_PERM:
+		return "octal permissions like 0644 or symbolic ones like o+w"
+	}
+	return "a whole number"
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: ParsePermissions                                                                     *
+*                                                                                            *
+* Description: Turns octal permissions such as 0755 or symbolic ones such as u+x,go+r into   *
+*              permission bits. Symbolic permissions give the bits that are set, who defaults*
+*              to a when left out                                                            *
+*                                                                                            *
+* Parameters: text : string - The permissions to parse                                       *
+*                                                                                            *
+* return: int64 - the permission bits                                                        *
+*         error - non-nil if the text is not valid permissions                               *
+**********************************************************************************************/
+func ParsePermissions(text string) (int64, error) {
+	if text != "" && text[0] >= '0' && text[0] <= '7' {
+		return strconv.ParseInt(text, 8, 32)
+	}
+
+	var mask int64
+	for _, clause := range strings.Split(text, ",") {
+		opIdx := strings.IndexAny(clause, "+=")
+		if opIdx < 0 || opIdx == len(clause)-1 {
+			return 0, fmt.Errorf("invalid permissions %q", text)
+		}
+
+		who := clause[:opIdx]
+		if who == "" {
+			who = "a"
+		}
+
+		var whoMask int64
+		for _, char := range who {
+			switch char {
+			case 'u':
+				whoMask |= 04700
+			case 'g':
+				whoMask |= 02070
+			case 'o':
+				whoMask |= 00007
+			case 'a':
+				whoMask |= 06777
+			default:
+				return 0, fmt.Errorf("invalid permissions %q", text)
+			}
+		}
+
+		var permMask int64
+		for _, char := range clause[opIdx+1:] {
+			switch char {
+			case 'r':
+				permMask |= 0444
+			case 'w':
+				permMask |= 0222
+			case 'x':
+				permMask |= 0111
+			case 's':
+				permMask |= 06000
+			case 't':
+				permMask |= 01000
+				whoMask |= 01000
+			default:
+				return 0, fmt.Errorf("invalid permissions %q", text)
+			}
+		}
+
+		mask |= whoMask & permMask
+	}
+
+	return mask, nil
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetUnixMode                                                                          *
+*                                                                                            *
+* Description: Returns the permission bits of a file as chmod writes them, including the     *
+*              setuid, setgid and sticky bits that fs.FileMode keeps elsewhere               *
+*                                                                                            *
+* Parameters: fileInfo : fs.FileInfo - The file                                              *
+*                                                                                            *
+* return: uint32 - the permission bits                                                       *
+**********************************************************************************************/
+func GetUnixMode(fileInfo fs.FileInfo) uint32 {
+	mode := fileInfo.Mode()
+	unixMode := uint32(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		unixMode |= 04000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		unixMode |= 02000
+	}
+	if mode&fs.ModeSticky != 0 {
+		unixMode |= 01000
+	}
+
+	return unixMode
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetStatField                                                                         *
+*                                                                                            *
+* Description: Returns a numeric field of the stat data of a file                            *
+*                                                                                            *
+* Parameters:  fileInfo : fs.FileInfo - The file                                             *
+*              field : string         - One of nlink, inode, uid, gid, dev or blocks         *
+*                                                                                            *
+* return: uint64 - the value, 0 if the stat data is not available                            *
+**********************************************************************************************/
+func GetStatField(fileInfo fs.FileInfo, field string) uint64 {
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0
+	}
+
+	switch field {
+	case "nlink":
+		return uint64(stat.Nlink)
+	case "inode":
+		return stat.Ino
+	case "uid":
+		return uint64(stat.Uid)
+	case "gid":
+		return uint64(stat.Gid)
+	case "dev":
+		return uint64(stat.Dev)
+	case "blocks":
+		return uint64(stat.Blocks)
+	}
+	return 0
+}
+
+/*********************************************************************************************
+*                                                                                            *
+* Name: GetStatTime                                                                          *
+*                                                                                            *
+* Description: Returns one of the timestamps of a file                                       *
+*                                                                                            *
+* Parameters:  fileInfo : fs.FileInfo - The file                                             *
+*              which : byte           - m for modification, a for access, c for status change*
+*                                                                                            *
+* return: time.Time - the timestamp, the modification time if stat data is not available     *
+**********************************************************************************************/
+func GetStatTime(fileInfo fs.FileInfo, which byte) time.Time {
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileInfo.ModTime()
+	}
+
+	accessTime, changeTime := GetAccessChangeTimes(stat)
+	switch which {
+	case 'a':
+		return accessTime
+	case 'c':
+		return changeTime
+	}
+	return fileInfo.ModTime()
+}